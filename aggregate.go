@@ -0,0 +1,167 @@
+package gtreap
+
+// Reducer folds an item into an accumulated value.  The acc is nil
+// for the first item of a group.
+type Reducer func(acc interface{}, i Item) interface{}
+
+// GroupVisitor is invoked with a group key and the reduced value of
+// the items in that group.  Return false to stop visiting.
+type GroupVisitor func(group interface{}, acc interface{}) bool
+
+// GroupReduce reduces the items of each group in a single ascending
+// pass, where the group of an item is given by the group func.  As
+// there is no hashing, a group is a run of adjacent items whose
+// group keys are == to each other, so the group func needs to be
+// consistent with the treap's ordering, like taking a key prefix,
+// and must return comparable values.
+func (t *Treap) GroupReduce(group func(Item) interface{}, reduce Reducer,
+	visitor GroupVisitor) {
+	var curGroup, curAcc interface{}
+	started := false
+	if !t.visitAll(t.top(), func(i Item) bool {
+		g := group(i)
+		if started && g != curGroup {
+			if !visitor(curGroup, curAcc) {
+				return false
+			}
+			curAcc = nil
+		}
+		curGroup, started = g, true
+		curAcc = reduce(curAcc, i)
+		return true
+	}) {
+		return
+	}
+	if started {
+		visitor(curGroup, curAcc)
+	}
+}
+
+// PrefixGroup returns a group func for GroupReduce that groups string
+// or []byte items by their first n bytes, returned as a string.
+// Items shorter than n bytes are grouped by their whole value.
+func PrefixGroup(n int) func(Item) interface{} {
+	return func(i Item) interface{} {
+		var s string
+		switch x := i.(type) {
+		case string:
+			s = x
+		case []byte:
+			s = string(x)
+		}
+		if len(s) > n {
+			s = s[:n]
+		}
+		return s
+	}
+}
+
+// Buckets returns the count of items in each bucket defined by the
+// ascending boundaries.  The result has len(boundaries)+1 entries:
+// result[0] counts items < boundaries[0], result[i] counts items >=
+// boundaries[i-1] and < boundaries[i], and the last entry counts
+// items >= the last boundary.  This is O(B lg N) for B boundaries.
+func (t *Treap) Buckets(boundaries []Item) []int {
+	res := make([]int, len(boundaries)+1)
+	prev := 0
+	for i, b := range boundaries {
+		rank := t.Rank(b)
+		res[i] = rank - prev
+		prev = rank
+	}
+	res[len(boundaries)] = t.Size() - prev
+	return res
+}
+
+// SplitIntoN returns up to n-1 ascending split items that divide the
+// treap into n ranges of nearly equal item counts, where each split
+// item is the first item of its range.  Fewer split items are
+// returned when the treap has less than n items.  This is O(n lg N).
+func (t *Treap) SplitIntoN(n int) []Item {
+	if n <= 1 {
+		return nil
+	}
+	count := t.Size()
+	var res []Item
+	prev := 0
+	for i := 1; i < n; i++ {
+		pos := i * count / n
+		if pos > prev {
+			res = append(res, t.Select(pos))
+			prev = pos
+		}
+	}
+	return res
+}
+
+// Downsample reduces the items of each bucket to one value, where the
+// ascending boundaries define len(boundaries)-1 buckets, the i-th
+// being [boundaries[i], boundaries[i+1]).  So for a time-keyed treap,
+// boundaries every minute over an hour give 60 points.  Each bucket
+// is scanned with a VisitRange, so items outside the boundaries are
+// never visited.  The result for an empty bucket is nil.
+func (t *Treap) Downsample(boundaries []Item, agg Reducer) []interface{} {
+	if len(boundaries) < 2 {
+		return nil
+	}
+	res := make([]interface{}, len(boundaries)-1)
+	for b := range res {
+		t.VisitRange(boundaries[b], boundaries[b+1], func(i Item) bool {
+			res[b] = agg(res[b], i)
+			return true
+		})
+	}
+	return res
+}
+
+// FindGaps reports the missing intervals among items in [ge, lt),
+// where next returns the item expected to follow an item, like the
+// next sequence number, in one ordered pass.  Each gap is given to
+// the visitor as [from, to), where from is the first missing item
+// and to is the next present item, or lt.  Return false from the
+// visitor to stop.  A nil ge or lt is unbounded, so there is no gap
+// before the first or after the last item.  A nil *Treap has no
+// compare func, so no gaps are reported.
+func (t *Treap) FindGaps(ge, lt Item, next func(Item) Item,
+	visitor func(from, to Item) bool) {
+	if t == nil {
+		return
+	}
+	expected := ge
+	done := false
+	t.VisitRange(ge, lt, func(i Item) bool {
+		if expected != nil && t.compare(expected, i) < 0 && !visitor(expected, i) {
+			done = true
+			return false
+		}
+		expected = next(i)
+		return true
+	})
+	if !done && expected != nil && lt != nil && t.compare(expected, lt) < 0 {
+		visitor(expected, lt)
+	}
+}
+
+// DedupBy returns a treap keeping only the first item of each run of
+// adjacent items whose projections are == to each other, like
+// GroupReduce, so the project func needs to be consistent with the
+// treap's ordering and must return comparable values.  The kept
+// items retain their priorities.
+func (t *Treap) DedupBy(project func(Item) interface{}) *Treap {
+	var dups []Item
+	var cur interface{}
+	started := false
+	t.visitAll(t.top(), func(i Item) bool {
+		p := project(i)
+		if started && p == cur {
+			dups = append(dups, i)
+		}
+		cur, started = p, true
+		return true
+	})
+	res := t
+	for _, i := range dups {
+		res = res.Delete(i)
+	}
+	return res
+}
@@ -0,0 +1,221 @@
+package gtreap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func countReducer(acc interface{}, i Item) interface{} {
+	if acc == nil {
+		return 1
+	}
+	return acc.(int) + 1
+}
+
+func TestGroupReduce(t *testing.T) {
+	x := NewTreap(stringCompare)
+
+	var groups []interface{}
+	var accs []interface{}
+	collect := func(group, acc interface{}) bool {
+		groups = append(groups, group)
+		accs = append(accs, acc)
+		return true
+	}
+
+	x.GroupReduce(PrefixGroup(2), countReducer, collect)
+	if len(groups) != 0 {
+		t.Errorf("expected no groups on empty treap, got: %v", groups)
+	}
+
+	x = load(x, []string{"t1:a", "t2:a", "t1:b", "t3:a", "t1:c", "t2:b", "t"})
+
+	x.GroupReduce(PrefixGroup(2), countReducer, collect)
+	if !reflect.DeepEqual(groups, []interface{}{"t", "t1", "t2", "t3"}) {
+		t.Errorf("unexpected groups: %v", groups)
+	}
+	if !reflect.DeepEqual(accs, []interface{}{1, 3, 2, 1}) {
+		t.Errorf("unexpected accs: %v", accs)
+	}
+
+	// Stopping early.
+	groups, accs = nil, nil
+	x.GroupReduce(PrefixGroup(2), countReducer, func(group, acc interface{}) bool {
+		collect(group, acc)
+		return len(groups) < 2
+	})
+	if !reflect.DeepEqual(groups, []interface{}{"t", "t1"}) {
+		t.Errorf("unexpected groups when stopping early: %v", groups)
+	}
+
+	// Reducing into values rather than counts.
+	groups, accs = nil, nil
+	x.GroupReduce(PrefixGroup(2), func(acc interface{}, i Item) interface{} {
+		if acc == nil {
+			return i.(string)
+		}
+		return acc.(string) + "," + i.(string)
+	}, collect)
+	if accs[1] != "t1:a,t1:b,t1:c" {
+		t.Errorf("unexpected concatenation: %v", accs[1])
+	}
+
+	if PrefixGroup(2)([]byte("abc")) != "ab" {
+		t.Errorf("expected PrefixGroup to handle []byte")
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if !reflect.DeepEqual(x.Buckets(nil), []int{0}) {
+		t.Errorf("expected a single empty bucket")
+	}
+	if !reflect.DeepEqual(x.Buckets([]Item{"b"}), []int{0, 0}) {
+		t.Errorf("expected two empty buckets")
+	}
+
+	x = load(x, []string{"a", "b", "c", "d", "e", "f"})
+	if !reflect.DeepEqual(x.Buckets(nil), []int{6}) {
+		t.Errorf("expected everything in one bucket")
+	}
+
+	tests := []struct {
+		boundaries []Item
+		exp        []int
+	}{
+		{[]Item{"c"}, []int{2, 4}},
+		{[]Item{"a"}, []int{0, 6}},
+		{[]Item{"z"}, []int{6, 0}},
+		{[]Item{"b", "b1", "e"}, []int{1, 1, 2, 2}},
+		{[]Item{"0", "c", "c", "z"}, []int{0, 2, 0, 4, 0}},
+	}
+	for testIdx, test := range tests {
+		got := x.Buckets(test.boundaries)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("test: %v, expected: %v, got: %v", testIdx, test.exp, got)
+		}
+	}
+}
+
+func TestSplitIntoN(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if x.SplitIntoN(4) != nil {
+		t.Errorf("expected no splits for an empty treap")
+	}
+
+	x = load(x, []string{"a", "b", "c", "d", "e", "f", "g", "h"})
+	tests := []struct {
+		n   int
+		exp []Item
+	}{
+		{0, nil},
+		{1, nil},
+		{2, []Item{"e"}},
+		{3, []Item{"c", "f"}},
+		{4, []Item{"c", "e", "g"}},
+		{8, []Item{"b", "c", "d", "e", "f", "g", "h"}},
+		{20, []Item{"b", "c", "d", "e", "f", "g", "h"}},
+	}
+	for testIdx, test := range tests {
+		got := x.SplitIntoN(test.n)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("test: %v, expected: %v, got: %v", testIdx, test.exp, got)
+		}
+		if len(got) > 0 {
+			counts := x.Buckets(got)
+			for _, c := range counts {
+				if c == 0 {
+					t.Errorf("test: %v, expected non-empty ranges, got: %v",
+						testIdx, counts)
+				}
+			}
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	x := NewTreap(intCompare)
+	if x.Downsample([]Item{0}, countReducer) != nil {
+		t.Errorf("expected no buckets for a single boundary")
+	}
+	if got := x.Downsample([]Item{0, 10}, countReducer); !reflect.DeepEqual(got, []interface{}{nil}) {
+		t.Errorf("expected an empty bucket, got: %v", got)
+	}
+
+	// Time-keyed points at 0..99.
+	for i := 0; i < 100; i++ {
+		x = x.Upsert(i, i*7919%100)
+	}
+	sum := func(acc interface{}, i Item) interface{} {
+		if acc == nil {
+			return i.(int)
+		}
+		return acc.(int) + i.(int)
+	}
+	got := x.Downsample([]Item{10, 20, 30, 200, 300}, sum)
+	exp := []interface{}{145, 245, 4515, nil}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected: %v, got: %v", exp, got)
+	}
+	got = x.Downsample([]Item{0, 25, 50, 75, 100}, countReducer)
+	if !reflect.DeepEqual(got, []interface{}{25, 25, 25, 25}) {
+		t.Errorf("expected equal counts, got: %v", got)
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	next := func(i Item) Item { return i.(int) + 1 }
+	gaps := func(x *Treap, ge, lt int, max int) [][2]Item {
+		var res [][2]Item
+		x.FindGaps(ge, lt, next, func(from, to Item) bool {
+			res = append(res, [2]Item{from, to})
+			return len(res) < max
+		})
+		return res
+	}
+
+	x := NewTreap(intCompare)
+	if got := gaps(x, 0, 10, 100); !reflect.DeepEqual(got, [][2]Item{{0, 10}}) {
+		t.Errorf("expected the whole range missing, got: %v", got)
+	}
+
+	for _, i := range []int{0, 1, 2, 5, 6, 9, 12} {
+		x = x.Upsert(i, i*7919%13)
+	}
+	tests := []struct {
+		ge, lt, max int
+		exp         [][2]Item
+	}{
+		{0, 10, 100, [][2]Item{{3, 5}, {7, 9}}},
+		{0, 11, 100, [][2]Item{{3, 5}, {7, 9}, {10, 11}}},
+		{0, 13, 100, [][2]Item{{3, 5}, {7, 9}, {10, 12}}},
+		{0, 13, 2, [][2]Item{{3, 5}, {7, 9}}},
+		{0, 13, 1, [][2]Item{{3, 5}}},
+		{4, 7, 100, [][2]Item{{4, 5}}},
+		{0, 3, 100, nil},
+	}
+	for _, test := range tests {
+		if got := gaps(x, test.ge, test.lt, test.max); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("[%v, %v), expected: %v, got: %v", test.ge, test.lt, test.exp, got)
+		}
+	}
+}
+
+func TestDedupBy(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"a1", "a2", "b1", "c1", "c2", "c3", "d"})
+	y := x.DedupBy(PrefixGroup(1))
+	if !reflect.DeepEqual(y.Items(), []Item{"a1", "b1", "c1", "d"}) {
+		t.Errorf("expected one item per prefix, got: %v", y.Items())
+	}
+	checkSizes(t, y.root)
+	checkHeap(t, y.root)
+	if x.Size() != 7 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+	if z := y.DedupBy(PrefixGroup(1)); !reflect.DeepEqual(z.Items(), y.Items()) {
+		t.Errorf("expected DedupBy to be idempotent, got: %v", z.Items())
+	}
+	if NewTreap(stringCompare).DedupBy(PrefixGroup(1)).Size() != 0 {
+		t.Errorf("expected an empty treap")
+	}
+}
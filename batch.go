@@ -0,0 +1,101 @@
+package gtreap
+
+import (
+	"sort"
+)
+
+// GetMany is like calling Get on each target, returning the results
+// in the same order as the targets.  The targets are visited in
+// sorted order, with each search starting from where the previous one
+// left off rather than from the root, which saves comparator calls
+// when the targets are dense relative to the treap.  Unsorted targets
+// first need to be sorted, though, so for a few targets or a cheap
+// compare func, independent Get's can be just as fast.
+func (t *Treap) GetMany(targets []Item) []Item {
+	res := make([]Item, len(targets))
+	for i, n := range t.findMany(targets) {
+		if n != nil {
+			res[i] = t.clone(n.item)
+		}
+	}
+	return res
+}
+
+// ContainsMany reports whether each target is in the treap, in the
+// same order as the targets, using the same sorted pass as GetMany.
+func (t *Treap) ContainsMany(targets []Item) []bool {
+	res := make([]bool, len(targets))
+	for i, n := range t.findMany(targets) {
+		res[i] = n != nil
+	}
+	return res
+}
+
+// findMany returns the nodes for the targets, nil when not found, in
+// the same order as the targets.
+func (t *Treap) findMany(targets []Item) []*node {
+	res := make([]*node, len(targets))
+	if t.top() == nil {
+		return res
+	}
+	order := make([]int, 0, len(targets))
+	sorted := true
+	for i, target := range targets {
+		if target == nil {
+			continue // Never found.
+		}
+		if sorted && len(order) > 0 &&
+			t.compare(targets[order[len(order)-1]], target) > 0 {
+			sorted = false
+		}
+		order = append(order, i)
+	}
+	if !sorted {
+		sort.Slice(order, func(i, j int) bool {
+			return t.compare(targets[order[i]], targets[order[j]]) < 0
+		})
+	}
+
+	// A finger search, where the stack holds the path to the last
+	// visited node, along with the upper bound of each path node's
+	// subtree, being its nearest ancestor reached by a left step.
+	type step struct {
+		n, bound *node
+	}
+	var buf [64]step
+	path := append(buf[:0], step{n: t.root})
+
+	for _, i := range order {
+		target := targets[i]
+		for len(path) > 1 {
+			b := path[len(path)-1].bound
+			if b != nil && t.compare(target, b.item) < 0 {
+				break
+			}
+			path = path[:len(path)-1]
+		}
+		for {
+			s := path[len(path)-1]
+			if s.n == nil {
+				break
+			}
+			c := t.compare(target, s.n.item)
+			if c == 0 {
+				res[i] = s.n
+				break
+			}
+			if c < 0 {
+				if s.n.left == nil {
+					break
+				}
+				path = append(path, step{n: s.n.left, bound: s.n})
+			} else {
+				if s.n.right == nil {
+					break
+				}
+				path = append(path, step{n: s.n.right, bound: s.bound})
+			}
+		}
+	}
+	return res
+}
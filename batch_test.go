@@ -0,0 +1,85 @@
+package gtreap
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetMany(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if got := x.GetMany([]Item{"a", "b"}); !reflect.DeepEqual(got, []Item{nil, nil}) {
+		t.Errorf("expected nils from an empty treap, got: %v", got)
+	}
+	if got := x.GetMany(nil); len(got) != 0 {
+		t.Errorf("expected no results for no targets, got: %v", got)
+	}
+
+	x = load(x, []string{"e", "b", "d", "a", "c", "g"})
+	targets := []Item{"d", "a", "zz", "d", "f", "g", "0", "c"}
+	exp := []Item{"d", "a", nil, "d", nil, "g", nil, "c"}
+	got := x.GetMany(targets)
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected: %v, got: %v", exp, got)
+	}
+	for i, target := range targets {
+		if x.Get(target) != got[i] {
+			t.Errorf("expected GetMany to match Get for %v", target)
+		}
+	}
+
+	y, keys := benchmarkTreap(10000)
+	targets = append(keys[:3000:3000], -1, 10000, 5000)
+	got = y.GetMany(targets)
+	for i, target := range targets {
+		if y.Get(target) != got[i] {
+			t.Errorf("expected GetMany to match Get for %v", target)
+		}
+	}
+}
+
+func TestContainsMany(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if got := x.ContainsMany([]Item{"a"}); !reflect.DeepEqual(got, []bool{false}) {
+		t.Errorf("expected false from an empty treap, got: %v", got)
+	}
+
+	x = load(x, []string{"b", "d", "f"})
+	got := x.ContainsMany([]Item{"f", "a", "b", "e", "b", "g", "d"})
+	exp := []bool{true, false, true, false, true, false, true}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected: %v, got: %v", exp, got)
+	}
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	x, keys := benchmarkTreap(100000)
+	targets := keys[:1000]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.GetMany(targets)
+	}
+}
+
+func BenchmarkGetManyAsGets(b *testing.B) {
+	x, keys := benchmarkTreap(100000)
+	targets := keys[:1000]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, target := range targets {
+			x.Get(target)
+		}
+	}
+}
+
+func BenchmarkGetManySorted(b *testing.B) {
+	x, keys := benchmarkTreap(100000)
+	targets := append([]Item(nil), keys[:1000]...)
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].(int) < targets[j].(int)
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.GetMany(targets)
+	}
+}
@@ -0,0 +1,239 @@
+package gtreap
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// BulkLoader is a sink for items delivered in ascending order, which
+// lets items be moved between gtreap and other ordered stores in one
+// call.  Builder is the BulkLoader for building a Treap.
+type BulkLoader interface {
+	Load(i Item) error
+}
+
+// CopyTo feeds all the items of the treap in ascending order to the
+// loader, stopping at and returning the first error from Load.
+func (t *Treap) CopyTo(dst BulkLoader) error {
+	var err error
+	t.visitAll(t.top(), func(i Item) bool {
+		err = dst.Load(i)
+		return err == nil
+	})
+	return err
+}
+
+// Builder builds a treap from items loaded in ascending order in
+// O(N), rather than O(N lg N) by upserting each item.  Items are given
+// random priorities.
+type Builder struct {
+	t     *Treap
+	spine []*node // Right spine of the treap built so far.
+	last  Item
+	n     int
+	bytes int  // Total size of the loaded items.
+	done  bool // Set by Treap, after which Load fails.
+}
+
+// NewBuilder returns a Builder for a treap with the given compare
+// func and options.
+func NewBuilder(c Compare, o Options) *Builder {
+	return &Builder{t: NewTreapWithOptions(c, o)}
+}
+
+// Load adds the next item, which must be greater than the previously
+// loaded item, otherwise ErrOutOfOrder is returned.  Errors from
+// Options.Normalize and the limits of Options are also returned, like
+// TryUpsert.  After Treap, ErrBuilderDone is returned.
+func (b *Builder) Load(i Item) error {
+	if b.done {
+		return ErrBuilderDone
+	}
+	t := b.t
+	i, err := t.normalize(i)
+	if err != nil {
+		return err
+	}
+	if b.n > 0 && t.compare(b.last, i) >= 0 {
+		return fmt.Errorf("%w: item %v after %v", ErrOutOfOrder, i, b.last)
+	}
+	if t.options != nil {
+		size := itemSize(i)
+		if t.options.MaxItemSize > 0 && size > t.options.MaxItemSize {
+			return &LimitError{
+				Limit: "MaxItemSize", Size: size, Max: t.options.MaxItemSize,
+			}
+		}
+		if t.limitsItemsSize() {
+			if b.bytes+size > t.options.MaxTotalSize {
+				return &LimitError{
+					Limit: "MaxTotalSize", Size: b.bytes + size,
+					Max: t.options.MaxTotalSize,
+				}
+			}
+			b.bytes += size
+		}
+	}
+	i = t.clone(i)
+	b.last = i
+	b.n++
+
+	// The new node goes at the end of the right spine, below the
+	// spine nodes with higher priorities, taking the lower priority
+	// rest of the spine as its left child.  Augmented fields are
+	// filled in by Treap, once all the children are known.
+	n := newNode(i, t.hashItem(i), rand.Int(), nil, nil)
+	var left *node
+	for len(b.spine) > 0 && b.spine[len(b.spine)-1].priority <= n.priority {
+		left = b.spine[len(b.spine)-1]
+		b.spine = b.spine[:len(b.spine)-1]
+	}
+	n.left = left
+	if len(b.spine) > 0 {
+		b.spine[len(b.spine)-1].right = n
+	}
+	b.spine = append(b.spine, n)
+	return nil
+}
+
+// Treap returns the built treap, finishing the Builder, so that
+// further Loads return ErrBuilderDone rather than changing the
+// returned treap.
+func (b *Builder) Treap() *Treap {
+	if !b.done && len(b.spine) > 0 {
+		b.t.root = b.spine[0]
+		augment(b.t.root)
+	}
+	b.spine, b.done = nil, true
+	res := *b.t
+	return &res
+}
+
+// augment fills in the augmented fields of freshly built nodes, whose
+// fields initially only cover their own item.
+func augment(n *node) {
+	if n == nil {
+		return
+	}
+	augment(n.left)
+	augment(n.right)
+	n.size += n.left.subtreeSize() + n.right.subtreeSize()
+	n.hash += n.left.subtreeHash() + n.right.subtreeHash()
+	n.bytes += n.left.subtreeBytes() + n.right.subtreeBytes()
+}
+
+// FromAscending builds a treap from an ordered structure, given its
+// ascend func, which must invoke its callback on every item in
+// ascending order of the compare func, stopping if the callback
+// returns false.  For example, with a github.com/google/btree BTree,
+// whose items are ordered the same way as by compare:
+//
+//	t, err := gtreap.FromAscending(compare, func(f func(gtreap.Item) bool) {
+//	    bt.Ascend(func(i btree.Item) bool { return f(i) })
+//	})
+func FromAscending(c Compare, ascend func(func(Item) bool)) (*Treap, error) {
+	b := NewBuilder(c, Options{})
+	var err error
+	ascend(func(i Item) bool {
+		err = b.Load(i)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Treap(), nil
+}
+
+// FromSyncMap builds a treap whose items are the values of the
+// sync.Map, as a map's values usually carry their own keys, so the
+// compare func needs to order values.  Of values that compare as
+// equal, only one is kept, and nil values are skipped.
+func FromSyncMap(m *sync.Map, c Compare) *Treap {
+	var items []Item
+	m.Range(func(k, v interface{}) bool {
+		if v != nil {
+			items = append(items, v)
+		}
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return c(items[i], items[j]) < 0
+	})
+	b := NewBuilder(c, Options{})
+	for i, item := range items {
+		if i > 0 && c(items[i-1], item) == 0 {
+			continue
+		}
+		b.Load(item)
+	}
+	return b.Treap()
+}
+
+// SortInto builds a treap from unordered items by sorting a copy of
+// them with a parallel merge sort and feeding that to a Builder.  The
+// sort is stable, so of items that compare as equal, the last one in
+// the slice is kept, as if the items had been upserted in order.  Nil
+// items are skipped.
+func SortInto(items []Item, c Compare) *Treap {
+	sorted := make([]Item, 0, len(items))
+	for _, i := range items {
+		if i != nil {
+			sorted = append(sorted, i)
+		}
+	}
+	depth := 0
+	for n := runtime.GOMAXPROCS(0); n > 1; n /= 2 {
+		depth++
+	}
+	mergeSort(sorted, make([]Item, len(sorted)), c, depth)
+	b := NewBuilder(c, Options{})
+	for i, item := range sorted {
+		if i+1 < len(sorted) && c(item, sorted[i+1]) == 0 {
+			continue
+		}
+		b.Load(item)
+	}
+	return b.Treap()
+}
+
+// Below this many items, mergeSort just sorts in the caller's
+// goroutine, as spawning more would cost more than it saves.
+const mergeSortCutoff = 4096
+
+// mergeSort stably sorts items, using buf, of the same length, as
+// scratch space, sorting the halves concurrently up to depth levels.
+func mergeSort(items, buf []Item, c Compare, depth int) {
+	if depth <= 0 || len(items) < mergeSortCutoff {
+		sort.SliceStable(items, func(i, j int) bool {
+			return c(items[i], items[j]) < 0
+		})
+		return
+	}
+	mid := len(items) / 2
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mergeSort(items[:mid], buf[:mid], c, depth-1)
+	}()
+	mergeSort(items[mid:], buf[mid:], c, depth-1)
+	wg.Wait()
+
+	i, j, k := 0, mid, 0
+	for i < mid && j < len(items) {
+		if c(items[j], items[i]) < 0 {
+			buf[k] = items[j]
+			j++
+		} else {
+			buf[k] = items[i]
+			i++
+		}
+		k++
+	}
+	k += copy(buf[k:], items[i:mid])
+	copy(buf[k:], items[j:])
+	copy(items, buf)
+}
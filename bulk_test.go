@@ -0,0 +1,207 @@
+package gtreap
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type sliceLoader struct {
+	items []Item
+	max   int
+}
+
+func (s *sliceLoader) Load(i Item) error {
+	if s.max > 0 && len(s.items) >= s.max {
+		return errors.New("full")
+	}
+	s.items = append(s.items, i)
+	return nil
+}
+
+func TestCopyTo(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"c", "a", "b"})
+	s := &sliceLoader{}
+	if err := x.CopyTo(s); err != nil {
+		t.Errorf("expected CopyTo to work, got: %v", err)
+	}
+	if !reflect.DeepEqual(s.items, []Item{"a", "b", "c"}) {
+		t.Errorf("unexpected items: %v", s.items)
+	}
+
+	s = &sliceLoader{max: 2}
+	if err := x.CopyTo(s); err == nil || err.Error() != "full" {
+		t.Errorf("expected the loader's error, got: %v", err)
+	}
+	if len(s.items) != 2 {
+		t.Errorf("expected CopyTo to stop at the error, got: %v", s.items)
+	}
+
+	// Copying between treaps.
+	b := NewBuilder(stringCompare, Options{})
+	if err := x.CopyTo(b); err != nil {
+		t.Errorf("expected CopyTo a Builder to work, got: %v", err)
+	}
+	if !reflect.DeepEqual(b.Treap().Items(), x.Items()) {
+		t.Errorf("expected the copy to have the same items")
+	}
+}
+
+func checkHeap(t *testing.T, n *node) {
+	if n == nil {
+		return
+	}
+	for _, c := range []*node{n.left, n.right} {
+		if c != nil && c.priority > n.priority {
+			t.Errorf("expected heap order, %v above %v", n.priority, c.priority)
+		}
+		checkHeap(t, c)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	if NewBuilder(intCompare, Options{}).Treap().Min() != nil {
+		t.Errorf("expected an empty treap from an empty Builder")
+	}
+
+	hash := func(i Item) uint64 { return uint64(i.(int)) * 31 }
+	b := NewBuilder(intCompare, Options{Hash: hash})
+	y := NewTreapWithOptions(intCompare, Options{Hash: hash})
+	for i := 0; i < 1000; i++ {
+		if err := b.Load(i); err != nil {
+			t.Fatalf("expected Load to work, got: %v", err)
+		}
+		y = y.Upsert(i, i)
+	}
+	if err := b.Load(999); !errors.Is(err, ErrOutOfOrder) {
+		t.Errorf("expected ErrOutOfOrder for a repeated item, got: %v", err)
+	}
+	if err := b.Load(5); !errors.Is(err, ErrOutOfOrder) {
+		t.Errorf("expected ErrOutOfOrder for a smaller item, got: %v", err)
+	}
+	x := b.Treap()
+	checkHeap(t, x.root)
+	if !reflect.DeepEqual(x.Items(), y.Items()) {
+		t.Errorf("expected built items to match upserted items")
+	}
+	if x.Fingerprint() != y.Fingerprint() {
+		t.Errorf("expected built fingerprint to match")
+	}
+	x = x.Upsert(1000, 1).Delete(0)
+	if x.Min() != 1 || x.Max() != 1000 {
+		t.Errorf("expected a built treap to work with Upsert and Delete")
+	}
+
+	b = NewBuilder(stringCompare, Options{MaxItemSize: 2, MaxTotalSize: 3})
+	if err := b.Load("aaa"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded for item size, got: %v", err)
+	}
+	if err := b.Load("aa"); err != nil {
+		t.Errorf("expected Load to work, got: %v", err)
+	}
+	if err := b.Load("bb"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded for total size, got: %v", err)
+	}
+	if x := b.Treap(); x.ItemsSize() != 2 || x.Get("aa") != "aa" {
+		t.Errorf("expected items size to be tracked")
+	}
+
+	b = NewBuilder(intCompare, Options{})
+	for i := 0; i < 10; i++ {
+		b.Load(i)
+	}
+	t1 := b.Treap()
+	if err := b.Load(10); err != ErrBuilderDone {
+		t.Errorf("expected ErrBuilderDone after Treap, got: %v", err)
+	}
+	if t2 := b.Treap(); t1.Size() != 10 || t2.Size() != 10 || t2 == t1 {
+		t.Errorf("expected the built treap to be unchanged, got sizes: %v, %v",
+			t1.Size(), t2.Size())
+	}
+}
+
+func TestFromAscending(t *testing.T) {
+	src := load(NewTreap(stringCompare), []string{"b", "c", "a"})
+	x, err := FromAscending(stringCompare, func(f func(Item) bool) {
+		src.VisitAscend("", f)
+	})
+	if err != nil || !reflect.DeepEqual(x.Items(), []Item{"a", "b", "c"}) {
+		t.Errorf("expected FromAscending to copy items, got: %v, %v", x, err)
+	}
+
+	_, err = FromAscending(stringCompare, func(f func(Item) bool) {
+		for _, s := range []string{"a", "c", "b", "d"} {
+			if !f(s) {
+				return
+			}
+		}
+	})
+	if !errors.Is(err, ErrOutOfOrder) {
+		t.Errorf("expected ErrOutOfOrder, got: %v", err)
+	}
+}
+
+func TestFromSyncMap(t *testing.T) {
+	var m sync.Map
+	if FromSyncMap(&m, stringCompare).Min() != nil {
+		t.Errorf("expected an empty treap from an empty map")
+	}
+	m.Store(3, "c")
+	m.Store(1, "a")
+	m.Store(2, "b")
+	m.Store(4, "a")
+	x := FromSyncMap(&m, stringCompare)
+	if !reflect.DeepEqual(x.Items(), []Item{"a", "b", "c"}) {
+		t.Errorf("expected the map's values as items, got: %v", x.Items())
+	}
+}
+
+func TestSortInto(t *testing.T) {
+	type entry struct{ key, seq int }
+	c := func(a, b interface{}) int {
+		return a.(entry).key - b.(entry).key
+	}
+	var items []Item
+	for i := 0; i < 3*mergeSortCutoff; i++ {
+		items = append(items, entry{key: (i * 7919) % 5000, seq: i})
+	}
+	last := map[int]int{}
+	for _, i := range items {
+		last[i.(entry).key] = i.(entry).seq
+	}
+	x := SortInto(items, c)
+	if x.Size() != 5000 {
+		t.Errorf("expected one item per key, got: %v", x.Size())
+	}
+	checkSizes(t, x.root)
+	checkHeap(t, x.root)
+	prev := -1
+	x.VisitAscend(entry{}, func(i Item) bool {
+		e := i.(entry)
+		if e.key <= prev {
+			t.Errorf("expected ascending keys, got: %v after %v", e.key, prev)
+		}
+		if e.seq != last[e.key] {
+			t.Errorf("expected the last item for key: %v, got seq: %v", e.key, e.seq)
+		}
+		prev = e.key
+		return true
+	})
+	if items[0].(entry).seq != 0 {
+		t.Errorf("expected the items to be left unchanged")
+	}
+
+	sorted := append([]Item(nil), items...)
+	mergeSort(sorted, make([]Item, len(sorted)), c, 3)
+	for i := 1; i < len(sorted); i++ {
+		a, b := sorted[i-1].(entry), sorted[i].(entry)
+		if a.key > b.key || (a.key == b.key && a.seq > b.seq) {
+			t.Fatalf("expected a stable sort, got: %v before %v", a, b)
+		}
+	}
+
+	if SortInto(nil, c).Size() != 0 {
+		t.Errorf("expected an empty treap")
+	}
+}
@@ -0,0 +1,26 @@
+package gtreap
+
+import (
+	"context"
+)
+
+// ItemsChan returns a channel streaming the items greater-than-or-equal
+// to the pivot in ascending order, for pipeline-style consumers.  The
+// channel is closed after the last item, or once the context is done,
+// at which point the producing goroutine exits, so consumers that
+// stop reading early must cancel the context to avoid leaking it.
+func (t *Treap) ItemsChan(ctx context.Context, pivot Item) <-chan Item {
+	ch := make(chan Item)
+	go func() {
+		defer close(ch)
+		t.VisitAscend(pivot, func(i Item) bool {
+			select {
+			case ch <- i:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	return ch
+}
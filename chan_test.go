@@ -0,0 +1,43 @@
+package gtreap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestItemsChan(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"c", "a", "d", "b"})
+
+	var got []Item
+	for i := range x.ItemsChan(context.Background(), "b") {
+		got = append(got, i)
+	}
+	if !reflect.DeepEqual(got, []Item{"b", "c", "d"}) {
+		t.Errorf("unexpected items: %v", got)
+	}
+
+	for range NewTreap(stringCompare).ItemsChan(context.Background(), "a") {
+		t.Errorf("expected no items from an empty treap")
+	}
+
+	// Cancelling closes the channel without reading everything.
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := x.ItemsChan(ctx, "a")
+	if i := <-ch; i != "a" {
+		t.Errorf("expected first item a, got: %v", i)
+	}
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected the channel to be closed after cancel")
+		}
+	}
+}
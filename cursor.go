@@ -0,0 +1,121 @@
+package gtreap
+
+// Cursor is a stateful position over the items of a treap, for
+// algorithms that need to advance through several treaps in
+// lockstep, like merges, where visitor callbacks are awkward.  As the
+// treap is immutable, a cursor stays valid while other versions of
+// the treap are created.  A new cursor is not positioned, so Valid
+// is false until a Seek, First or Last.
+type Cursor struct {
+	t     *Treap
+	stack []*node // Path from the root to the current node.
+}
+
+// Cursor returns a new, unpositioned cursor over the treap.
+func (t *Treap) Cursor() *Cursor {
+	return &Cursor{t: t}
+}
+
+// Valid returns whether the cursor is positioned on an item.
+func (c *Cursor) Valid() bool {
+	return len(c.stack) > 0
+}
+
+// Item returns the item at the cursor, or nil if not Valid.
+func (c *Cursor) Item() Item {
+	if len(c.stack) == 0 {
+		return nil
+	}
+	return c.t.clone(c.stack[len(c.stack)-1].item)
+}
+
+// Seek positions the cursor on the smallest item greater-than-or-equal
+// to the target, and returns Valid.
+func (c *Cursor) Seek(target Item) bool {
+	c.stack = c.stack[:0]
+	found := -1 // Index in the stack of the best item so far.
+	n := c.t.top()
+	for n != nil && target != nil {
+		c.stack = append(c.stack, n)
+		cmp := c.t.compare(target, n.item)
+		if cmp <= 0 {
+			found = len(c.stack) - 1
+			if cmp == 0 {
+				break
+			}
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	c.stack = c.stack[:found+1]
+	return c.Valid()
+}
+
+// First positions the cursor on the smallest item, and returns Valid.
+func (c *Cursor) First() bool {
+	c.stack = c.stack[:0]
+	c.pushLeft(c.t.top())
+	return c.Valid()
+}
+
+// Last positions the cursor on the greatest item, and returns Valid.
+func (c *Cursor) Last() bool {
+	c.stack = c.stack[:0]
+	c.pushRight(c.t.top())
+	return c.Valid()
+}
+
+// Next moves the cursor to the next greater item, and returns Valid,
+// which is false after moving past the greatest item.
+func (c *Cursor) Next() bool {
+	if len(c.stack) == 0 {
+		return false
+	}
+	n := c.stack[len(c.stack)-1]
+	if n.right != nil {
+		c.pushLeft(n.right)
+		return true
+	}
+	// Go up until arriving from a left child.
+	for {
+		c.stack = c.stack[:len(c.stack)-1]
+		if len(c.stack) == 0 || c.stack[len(c.stack)-1].left == n {
+			return c.Valid()
+		}
+		n = c.stack[len(c.stack)-1]
+	}
+}
+
+// Prev moves the cursor to the next smaller item, and returns Valid,
+// which is false after moving past the smallest item.
+func (c *Cursor) Prev() bool {
+	if len(c.stack) == 0 {
+		return false
+	}
+	n := c.stack[len(c.stack)-1]
+	if n.left != nil {
+		c.pushRight(n.left)
+		return true
+	}
+	// Go up until arriving from a right child.
+	for {
+		c.stack = c.stack[:len(c.stack)-1]
+		if len(c.stack) == 0 || c.stack[len(c.stack)-1].right == n {
+			return c.Valid()
+		}
+		n = c.stack[len(c.stack)-1]
+	}
+}
+
+func (c *Cursor) pushLeft(n *node) {
+	for ; n != nil; n = n.left {
+		c.stack = append(c.stack, n)
+	}
+}
+
+func (c *Cursor) pushRight(n *node) {
+	for ; n != nil; n = n.right {
+		c.stack = append(c.stack, n)
+	}
+}
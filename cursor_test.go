@@ -0,0 +1,99 @@
+package gtreap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	c := NewTreap(stringCompare).Cursor()
+	if c.Valid() || c.Item() != nil || c.Next() || c.Prev() {
+		t.Errorf("expected an unpositioned cursor to be invalid")
+	}
+	if c.First() || c.Last() || c.Seek("a") {
+		t.Errorf("expected no positions in an empty treap")
+	}
+
+	x := load(NewTreap(stringCompare), []string{"e", "c", "a", "d", "b", "f"})
+	c = x.Cursor()
+
+	var got []Item
+	for ok := c.First(); ok; ok = c.Next() {
+		got = append(got, c.Item())
+	}
+	if !reflect.DeepEqual(got, []Item{"a", "b", "c", "d", "e", "f"}) {
+		t.Errorf("unexpected forward iteration: %v", got)
+	}
+	if c.Valid() || c.Next() {
+		t.Errorf("expected the cursor to be invalid past the end")
+	}
+
+	got = nil
+	for ok := c.Last(); ok; ok = c.Prev() {
+		got = append(got, c.Item())
+	}
+	if !reflect.DeepEqual(got, []Item{"f", "e", "d", "c", "b", "a"}) {
+		t.Errorf("unexpected backward iteration: %v", got)
+	}
+
+	tests := []struct {
+		target string
+		exp    Item
+	}{
+		{"0", "a"}, {"a", "a"}, {"b1", "c"}, {"d", "d"}, {"f", "f"}, {"g", nil},
+	}
+	for _, test := range tests {
+		ok := c.Seek(test.target)
+		if ok != (test.exp != nil) || c.Item() != test.exp {
+			t.Errorf("expected Seek(%v) at: %v, got: %v", test.target, test.exp, c.Item())
+		}
+	}
+
+	// Mixing directions.
+	c.Seek("c")
+	c.Next()
+	c.Prev()
+	c.Prev()
+	if c.Item() != "b" {
+		t.Errorf("expected b after mixed moves, got: %v", c.Item())
+	}
+
+	// Cursors keep working over their version of the treap.
+	c.Seek("c")
+	y := x.Delete("d").Delete("c")
+	c.Next()
+	if c.Item() != "d" || y.Get("d") != nil {
+		t.Errorf("expected the cursor to be unaffected by new versions")
+	}
+
+	// Interleaving two cursors, as in a merge.
+	z := load(NewTreap(stringCompare), []string{"a1", "c1", "g"})
+	ca, cb := x.Cursor(), z.Cursor()
+	ca.First()
+	cb.First()
+	got = nil
+	for ca.Valid() || cb.Valid() {
+		if !cb.Valid() || (ca.Valid() && stringCompare(ca.Item(), cb.Item()) < 0) {
+			got = append(got, ca.Item())
+			ca.Next()
+		} else {
+			got = append(got, cb.Item())
+			cb.Next()
+		}
+	}
+	exp := []Item{"a", "a1", "b", "c", "c1", "d", "e", "f", "g"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected merged: %v, got: %v", exp, got)
+	}
+
+	// Full iteration over a larger treap matches Items.
+	w, _ := benchmarkTreap(500)
+	got = nil
+	cw := w.Cursor()
+	for ok := cw.First(); ok; ok = cw.Next() {
+		got = append(got, cw.Item())
+	}
+	if !reflect.DeepEqual(got, w.Items()) {
+		t.Errorf("expected cursor iteration to match Items")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !gtreap_debug
+// +build !gtreap_debug
+
+package gtreap
+
+const debug = false
@@ -0,0 +1,8 @@
+//go:build gtreap_debug
+// +build gtreap_debug
+
+package gtreap
+
+// debug enables checks of caller invariants that are too costly to do
+// otherwise, like Join's ordering.  Build with -tags gtreap_debug.
+const debug = true
@@ -0,0 +1,16 @@
+//go:build gtreap_debug
+// +build gtreap_debug
+
+package gtreap
+
+import "testing"
+
+func TestJoinDebugCheck(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"a", "b", "c"})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Join of overlapping treaps to panic")
+		}
+	}()
+	x.Join(load(NewTreap(stringCompare), []string{"b"}))
+}
@@ -0,0 +1,42 @@
+package gtreap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned by TryUpsert and TryDelete when an
+// item or the treap would go beyond the limits in Options.  The returned error
+// is a *LimitError, so use errors.Is to check for it.
+var ErrLimitExceeded = errors.New("gtreap: limit exceeded")
+
+// LimitError describes which limit from Options was exceeded.
+type LimitError struct {
+	Limit string // Name of the Options field, like "MaxItemSize".
+	Size  int    // The approximate size or depth that was refused.
+	Max   int    // The configured limit.
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: size %d > %s %d",
+		ErrLimitExceeded, e.Size, e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// ErrOutOfOrder is returned when items are not in ascending order
+// where they are required to be, like Builder.Load.
+var ErrOutOfOrder = errors.New("gtreap: item out of order")
+
+// ErrBuilderDone is returned by Builder.Load after Builder.Treap.
+var ErrBuilderDone = errors.New("gtreap: builder already finished")
+
+// ErrNilItem is returned by TryUpsert and Builder.Load for a nil item,
+// or when Options.Normalize returns nil, as nil is never an item.
+var ErrNilItem = errors.New("gtreap: nil item")
+
+// ErrNilTreap is returned by TryUpsert on a nil *Treap, which has no
+// compare func to insert the item with.
+var ErrNilTreap = errors.New("gtreap: nil treap")
@@ -0,0 +1,24 @@
+package gtreap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLimitError(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{MaxItemSize: 1})
+	_, err := x.TryUpsert("aa", 1)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected errors.Is ErrLimitExceeded, got: %v", err)
+	}
+	var le *LimitError
+	if !errors.As(err, &le) {
+		t.Fatalf("expected a *LimitError, got: %#v", err)
+	}
+	if le.Limit != "MaxItemSize" || le.Size != 2 || le.Max != 1 {
+		t.Errorf("unexpected LimitError: %#v", le)
+	}
+	if err.Error() != "gtreap: limit exceeded: size 2 > MaxItemSize 1" {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
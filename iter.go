@@ -0,0 +1,32 @@
+//go:build go1.23
+// +build go1.23
+
+package gtreap
+
+import (
+	"iter"
+)
+
+// All returns an iterator over all the items in ascending order, for
+// use with range-over-func, like: for item := range t.All() { ... }
+func (t *Treap) All() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.Range(yield)
+	}
+}
+
+// Ascend returns an iterator over the items greater-than-or-equal to
+// the pivot, in ascending order, like VisitAscend.
+func (t *Treap) Ascend(pivot Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.VisitAscend(pivot, yield)
+	}
+}
+
+// Descend returns an iterator over the items less-than-or-equal to
+// the pivot, in descending order, like VisitDescend.
+func (t *Treap) Descend(pivot Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.VisitDescend(pivot, yield)
+	}
+}
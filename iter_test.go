@@ -0,0 +1,44 @@
+//go:build go1.23
+// +build go1.23
+
+package gtreap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"c", "a", "d", "b"})
+
+	var got []Item
+	for i := range x.All() {
+		got = append(got, i)
+	}
+	if !reflect.DeepEqual(got, []Item{"a", "b", "c", "d"}) {
+		t.Errorf("unexpected All: %v", got)
+	}
+
+	got = nil
+	for i := range x.Ascend("b") {
+		got = append(got, i)
+		if i == "c" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, []Item{"b", "c"}) {
+		t.Errorf("unexpected Ascend with break: %v", got)
+	}
+
+	got = nil
+	for i := range x.Descend("c1") {
+		got = append(got, i)
+	}
+	if !reflect.DeepEqual(got, []Item{"c", "b", "a"}) {
+		t.Errorf("unexpected Descend: %v", got)
+	}
+
+	for range NewTreap(stringCompare).All() {
+		t.Errorf("expected no items from an empty treap")
+	}
+}
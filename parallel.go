@@ -0,0 +1,125 @@
+package gtreap
+
+import (
+	"sync"
+)
+
+// ParallelVisit is like VisitAscend, but invokes the visitor on items
+// greater-than-or-equal to the pivot from the given number of worker
+// goroutines, so CPU-bound per-item processing can use many cores.
+// Items are delivered in no particular order and the visitor must be
+// safe for concurrent use.  Once a visitor invocation returns false,
+// no more items are handed out, although invocations already under
+// way still complete.  ParallelVisit returns after every visitor
+// invocation has returned.  With workers <= 1, this is VisitAscend.
+// For delivery in key order, use ParallelVisitOrdered.
+func (t *Treap) ParallelVisit(pivot Item, workers int, visitor ItemVisitor) {
+	if workers <= 1 {
+		t.VisitAscend(pivot, visitor)
+		return
+	}
+
+	items := make(chan Item, workers)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				select {
+				case <-stop:
+					continue // Drain without visiting.
+				default:
+				}
+				if !visitor(i) {
+					stopOnce.Do(func() { close(stop) })
+				}
+			}
+		}()
+	}
+
+	t.VisitAscend(pivot, func(i Item) bool {
+		select {
+		case items <- i:
+			return true
+		case <-stop:
+			return false
+		}
+	})
+	close(items)
+	wg.Wait()
+}
+
+// ParallelVisitOrdered is like ParallelVisit, but for delivery in key
+// order, the work on each item is split between process, invoked on
+// the items from the given number of worker goroutines, and the
+// visitor, invoked from the calling goroutine on the results of
+// process in the order of their items, as with VisitAscend.  Only
+// process must be safe for concurrent use, and it runs at most a
+// bounded number of items ahead of the visitor.  Once the visitor
+// returns false, no more items are handed out.  With workers <= 1,
+// process and the visitor are invoked in turn from VisitAscend.
+func (t *Treap) ParallelVisitOrdered(pivot Item, workers int,
+	process func(Item) Item, visitor ItemVisitor) {
+	if workers <= 1 {
+		t.VisitAscend(pivot, func(i Item) bool { return visitor(process(i)) })
+		return
+	}
+
+	type job struct {
+		item   Item
+		result chan Item
+	}
+	jobs := make(chan job, workers)
+	// The result channels of the jobs, in the order of their items.
+	results := make(chan chan Item, workers)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				select {
+				case <-stop:
+					continue // Drain without processing.
+				default:
+				}
+				j.result <- process(j.item)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t.VisitAscend(pivot, func(i Item) bool {
+			j := job{item: i, result: make(chan Item, 1)}
+			select {
+			case results <- j.result:
+			case <-stop:
+				return false
+			}
+			select {
+			case jobs <- j:
+				return true
+			case <-stop:
+				return false
+			}
+		})
+		close(jobs)
+		close(results)
+	}()
+
+	for r := range results {
+		if !visitor(<-r) {
+			close(stop)
+			break
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package gtreap
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParallelVisit(t *testing.T) {
+	x := NewTreap(intCompare)
+	for i := 0; i < 1000; i++ {
+		x = x.Upsert(i, (i*7919)%1000)
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		var m sync.Mutex
+		var seen []int
+		x.ParallelVisit(100, workers, func(i Item) bool {
+			m.Lock()
+			seen = append(seen, i.(int))
+			m.Unlock()
+			return true
+		})
+		sort.Ints(seen)
+		if len(seen) != 900 {
+			t.Fatalf("workers: %v, expected 900 items, got: %v", workers, len(seen))
+		}
+		for j, v := range seen {
+			if v != 100+j {
+				t.Fatalf("workers: %v, expected item %v, got: %v", workers, 100+j, v)
+			}
+		}
+	}
+
+	// Stopping early hands out no more items.
+	var calls int64
+	x.ParallelVisit(0, 4, func(i Item) bool {
+		return atomic.AddInt64(&calls, 1) < 10
+	})
+	if n := atomic.LoadInt64(&calls); n < 10 || n > 10+4*2 {
+		t.Errorf("expected visiting to stop soon after 10 calls, got: %v", n)
+	}
+
+	NewTreap(intCompare).ParallelVisit(0, 4, func(i Item) bool {
+		t.Errorf("expected no callbacks on an empty treap")
+		return true
+	})
+}
+
+func TestParallelVisitOrdered(t *testing.T) {
+	x := NewTreap(intCompare)
+	for i := 0; i < 1000; i++ {
+		x = x.Upsert(i, (i*7919)%1000)
+	}
+	double := func(i Item) Item { return i.(int) * 2 }
+
+	for _, workers := range []int{0, 1, 4} {
+		var seen []int
+		x.ParallelVisitOrdered(100, workers, double, func(i Item) bool {
+			seen = append(seen, i.(int))
+			return true
+		})
+		if len(seen) != 900 {
+			t.Fatalf("workers: %v, expected 900 results, got: %v", workers, len(seen))
+		}
+		for j, v := range seen {
+			if v != (100+j)*2 {
+				t.Fatalf("workers: %v, expected result %v, got: %v", workers, (100+j)*2, v)
+			}
+		}
+	}
+
+	// Stopping early hands out no more items.
+	var processed int64
+	var visited []int
+	x.ParallelVisitOrdered(0, 4, func(i Item) Item {
+		atomic.AddInt64(&processed, 1)
+		return i
+	}, func(i Item) bool {
+		visited = append(visited, i.(int))
+		return len(visited) < 10
+	})
+	if !reflect.DeepEqual(visited, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("expected the first 10 items in order, got: %v", visited)
+	}
+	if n := atomic.LoadInt64(&processed); n < 10 || n > 10+4*3 {
+		t.Errorf("expected processing to stop soon after 10 items, got: %v", n)
+	}
+
+	NewTreap(intCompare).ParallelVisitOrdered(0, 4, double, func(i Item) bool {
+		t.Errorf("expected no callbacks on an empty treap")
+		return true
+	})
+}
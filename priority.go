@@ -0,0 +1,22 @@
+package gtreap
+
+import (
+	"math"
+	"math/bits"
+)
+
+// FloatPriority maps a float64 priority, like a weight or deadline,
+// to an int priority with the same order, for use with Upsert, as
+// priorities are ints.  Composite priorities, like a deadline then a
+// weight, can be packed into the high and low bits of an int the
+// same way.  On 32-bit platforms, only the high 32 bits of the
+// ordering are kept, so close floats may get equal priorities.
+func FloatPriority(f float64) int {
+	u := math.Float64bits(f)
+	if u>>63 != 0 {
+		u = ^u // Negative floats order in reverse of their bits.
+	} else {
+		u |= 1 << 63
+	}
+	return int(int64(u^(1<<63)) >> (64 - bits.UintSize))
+}
@@ -0,0 +1,37 @@
+package gtreap
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestFloatPriority(t *testing.T) {
+	fs := []float64{math.Inf(-1), -1e300, -2.5, -1, -1e-300, 0, 1e-300,
+		0.5, 1, 1.5, 2, 1e300, math.Inf(1)}
+	for i := 1; i < len(fs); i++ {
+		if FloatPriority(fs[i-1]) >= FloatPriority(fs[i]) {
+			t.Errorf("expected %v < %v, got: %v >= %v", fs[i-1], fs[i],
+				FloatPriority(fs[i-1]), FloatPriority(fs[i]))
+		}
+	}
+	if FloatPriority(math.Copysign(0, -1)) > FloatPriority(0) {
+		t.Errorf("expected -0 to not be above 0")
+	}
+
+	// The highest float priority ends up at the root.
+	x := NewTreap(stringCompare)
+	weights := map[string]float64{"a": 0.25, "b": 3.5, "c": -1, "d": 3.25}
+	var keys []string
+	for k := range weights {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		x = x.Upsert(k, FloatPriority(weights[k]))
+	}
+	if x.root.item != "b" {
+		t.Errorf("expected b at the root, got: %v", x.root.item)
+	}
+	checkHeap(t, x.root)
+}
@@ -0,0 +1,139 @@
+package gtreap
+
+// Query is a declarative range query, so services can accept query
+// specs and have the bound and limit logic applied in one place.  The
+// zero value of each field means no constraint, so a zero Query
+// visits every item in ascending order, and by default the range is
+// [Lower, Upper) like VisitRange.
+type Query struct {
+	Lower, Upper Item // Bounds of the range, nil for unbounded.
+
+	ExcludeLower bool // When true, the Lower item itself is excluded.
+	IncludeUpper bool // When true, the Upper item itself is included.
+
+	// Filter, when non-nil, must return true for an item in range to
+	// be visited.
+	Filter func(Item) bool
+
+	// Limit, when > 0, is the max number of items visited.
+	Limit int
+
+	// Reverse, when true, visits in descending order.
+	Reverse bool
+}
+
+// Run visits the items matching the query, in the query's order,
+// until the visitor returns false.
+func (t *Treap) Run(q Query, visitor ItemVisitor) {
+	if t.top() == nil {
+		return
+	}
+	visited := 0
+	visit := func(i Item) bool {
+		if q.Filter != nil && !q.Filter(i) {
+			return true
+		}
+		visited++
+		return visitor(i) && (q.Limit <= 0 || visited < q.Limit)
+	}
+
+	if !q.Reverse {
+		t.VisitAscend(q.Lower, func(i Item) bool {
+			if !q.aboveLower(t, i) {
+				return true // An excluded Lower item.
+			}
+			return q.belowUpper(t, i) && visit(i)
+		})
+		return
+	}
+
+	t.VisitDescend(q.Upper, func(i Item) bool {
+		if !q.belowUpper(t, i) {
+			return true // An excluded Upper item.
+		}
+		return q.aboveLower(t, i) && visit(i)
+	})
+}
+
+func (q *Query) aboveLower(t *Treap, i Item) bool {
+	if q.Lower == nil {
+		return true
+	}
+	c := t.compare(i, q.Lower)
+	return c > 0 || (c == 0 && !q.ExcludeLower)
+}
+
+func (q *Query) belowUpper(t *Treap, i Item) bool {
+	if q.Upper == nil {
+		return true
+	}
+	c := t.compare(i, q.Upper)
+	return c < 0 || (c == 0 && q.IncludeUpper)
+}
+
+// QueryPlan is the result of Explain.
+type QueryPlan struct {
+	// RangeItems is the exact number of items within the bounds of
+	// the query, before any Filter or Limit.
+	RangeItems int
+
+	// Scanned estimates how many items Run will visit internally.
+	// Without a Filter, this is RangeItems capped by the Limit.  With
+	// a Filter, this is the worst case of RangeItems, as there is no
+	// telling how many items the filter rejects.
+	Scanned int
+
+	// FilterScansRange is true when a Filter can force Run to walk
+	// the whole range to find Limit matching items.
+	FilterScansRange bool
+
+	// Depth is the number of nodes on the path Run descends, from
+	// the root to a leaf, to find the first item of the range.
+	Depth int
+}
+
+// Explain reports, without running the query, how much work Run
+// would do for the query, in O(lg N).
+func (t *Treap) Explain(q Query) QueryPlan {
+	lo, hi := 0, t.Size()
+	if q.Lower != nil {
+		lo = t.Rank(q.Lower)
+		if q.ExcludeLower && t.find(q.Lower) != nil {
+			lo++
+		}
+	}
+	if q.Upper != nil {
+		hi = t.Rank(q.Upper)
+		if q.IncludeUpper && t.find(q.Upper) != nil {
+			hi++
+		}
+	}
+	var p QueryPlan
+	if hi > lo {
+		p.RangeItems = hi - lo
+	}
+	p.Scanned = p.RangeItems
+	p.FilterScansRange = q.Filter != nil
+	if !p.FilterScansRange && q.Limit > 0 && q.Limit < p.Scanned {
+		p.Scanned = q.Limit
+	}
+
+	// Like VisitAscend and VisitDescend, descend past an equal item
+	// to a leaf, or along the outer spine for a nil bound.
+	start, ascend := q.Lower, true
+	if q.Reverse {
+		start, ascend = q.Upper, false
+	}
+	for n := t.top(); n != nil; p.Depth++ {
+		c := 0
+		if start != nil {
+			c = t.compare(start, n.item)
+		}
+		if c < 0 || (c == 0 && ascend) {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return p
+}
@@ -0,0 +1,121 @@
+package gtreap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func runExpect(t *testing.T, x *Treap, q Query, exp []Item) {
+	var got []Item
+	x.Run(q, func(i Item) bool {
+		got = append(got, i)
+		return true
+	})
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("query: %+v, expected: %v, got: %v", q, exp, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	runExpect(t, NewTreap(stringCompare), Query{}, nil)
+	runExpect(t, NewTreap(stringCompare), Query{Reverse: true}, nil)
+
+	x := load(NewTreap(stringCompare), []string{"c", "a", "e", "b", "d", "f"})
+	notD := func(i Item) bool { return i != "d" }
+
+	tests := []struct {
+		q   Query
+		exp []Item
+	}{
+		{Query{}, []Item{"a", "b", "c", "d", "e", "f"}},
+		{Query{Reverse: true}, []Item{"f", "e", "d", "c", "b", "a"}},
+		{Query{Lower: "b", Upper: "e"}, []Item{"b", "c", "d"}},
+		{Query{Lower: "b", Upper: "e", ExcludeLower: true}, []Item{"c", "d"}},
+		{Query{Lower: "b", Upper: "e", IncludeUpper: true}, []Item{"b", "c", "d", "e"}},
+		{Query{Lower: "b", Upper: "e", Reverse: true}, []Item{"d", "c", "b"}},
+		{Query{Lower: "b", Upper: "e", Reverse: true, ExcludeLower: true,
+			IncludeUpper: true}, []Item{"e", "d", "c"}},
+		{Query{Lower: "b1", Upper: "d1"}, []Item{"c", "d"}},
+		{Query{Lower: "d"}, []Item{"d", "e", "f"}},
+		{Query{Upper: "c", Reverse: true}, []Item{"b", "a"}},
+		{Query{Limit: 2}, []Item{"a", "b"}},
+		{Query{Limit: 2, Reverse: true}, []Item{"f", "e"}},
+		{Query{Filter: notD, Lower: "c", Limit: 2}, []Item{"c", "e"}},
+		{Query{Filter: notD, Upper: "f", Reverse: true, Limit: 2}, []Item{"e", "c"}},
+		{Query{Lower: "e", Upper: "b"}, nil},
+		{Query{Lower: "z"}, nil},
+		{Query{Upper: "0", Reverse: true}, nil},
+	}
+	for _, test := range tests {
+		runExpect(t, x, test.q, test.exp)
+	}
+
+	n := 0
+	x.Run(Query{}, func(i Item) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("expected Run to stop when the visitor returns false, got: %v", n)
+	}
+
+	// Unbounded queries clone only the items visited.
+	clones := 0
+	y := NewTreapWithOptions(func(a, b interface{}) int {
+		return stringCompare(a.(*countedClone).key, b.(*countedClone).key)
+	}, Options{CloneItems: true})
+	for i, k := range []string{"a", "b", "c", "d"} {
+		y = y.Upsert(&countedClone{cloneItem{key: k}, &clones}, i)
+	}
+	for _, q := range []Query{{}, {Reverse: true}} {
+		clones = 0
+		y.Run(q, func(i Item) bool { return true })
+		if clones != 4 {
+			t.Errorf("query: %+v, expected 4 clones, got: %v", q, clones)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	if p := NewTreap(stringCompare).Explain(Query{}); p != (QueryPlan{}) {
+		t.Errorf("expected an empty plan for an empty treap, got: %+v", p)
+	}
+
+	// A left leaning linked list of f, e, d, c, b, a, so depths are
+	// easy to predict.
+	x := NewTreap(stringCompare)
+	for i, s := range []string{"a", "b", "c", "d", "e", "f"} {
+		x = x.Upsert(s, i)
+	}
+	notD := func(i Item) bool { return i != "d" }
+
+	tests := []struct {
+		q   Query
+		exp QueryPlan
+	}{
+		{Query{}, QueryPlan{6, 6, false, 6}},
+		{Query{Reverse: true}, QueryPlan{6, 6, false, 1}},
+		{Query{Lower: "b", Upper: "e"}, QueryPlan{3, 3, false, 6}},
+		{Query{Lower: "b", Upper: "e", ExcludeLower: true, IncludeUpper: true},
+			QueryPlan{3, 3, false, 6}},
+		{Query{Lower: "b1", Upper: "d1", Reverse: true}, QueryPlan{2, 2, false, 3}},
+		{Query{Limit: 2}, QueryPlan{6, 2, false, 6}},
+		{Query{Limit: 2, Filter: notD}, QueryPlan{6, 6, true, 6}},
+		{Query{Lower: "e", Upper: "b"}, QueryPlan{0, 0, false, 3}},
+		{Query{Lower: "e", Upper: "f", Reverse: true}, QueryPlan{1, 1, false, 1}},
+	}
+	for _, test := range tests {
+		if got := x.Explain(test.q); got != test.exp {
+			t.Errorf("query: %+v, expected: %+v, got: %+v", test.q, test.exp, got)
+		}
+		n := 0
+		x.Run(test.q, func(i Item) bool {
+			n++
+			return true
+		})
+		if test.q.Filter == nil && n != test.exp.Scanned {
+			t.Errorf("query: %+v, expected Run to visit %v, got: %v",
+				test.q, test.exp.Scanned, n)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package gtreap
+
+// UnionWith returns a treap of the items in either treap, in
+// O(M lg(N/M)) for treaps of sizes M <= N, rather than upserting the
+// items one by one.  Both treaps must be ordered by the same compare
+// func; the result uses the receiver's compare func and options.  For
+// items in both, resolve is given the receiver's item and the other's
+// item, and must return an equal item to keep, or nil to keep the
+// receiver's item.  A nil resolve keeps the other's item.
+func (t *Treap) UnionWith(other *Treap, resolve func(a, b Item) Item) *Treap {
+	if t == nil {
+		return other
+	}
+	return t.withRoot(t.unionWith(t.top(), other.top(), resolve))
+}
+
+// unionWith is like union, but resolves equal items.
+func (t *Treap) unionWith(this, that *node, resolve func(a, b Item) Item) *node {
+	if this == nil {
+		return that
+	}
+	if that == nil {
+		return this
+	}
+	if this.priority > that.priority {
+		left, middle, right := t.split(that, this.item)
+		l, r := t.unionWith(this.left, left, resolve), t.unionWith(this.right, right, resolve)
+		if middle == nil {
+			return this.with(l, r)
+		}
+		return t.resolved(this.item, middle.item, this.priority, l, r, resolve)
+	}
+	left, middle, right := t.split(this, that.item)
+	l, r := t.unionWith(left, that.left, resolve), t.unionWith(right, that.right, resolve)
+	if middle == nil {
+		return that.with(l, r)
+	}
+	return t.resolved(middle.item, that.item, that.priority, l, r, resolve)
+}
+
+// resolved returns a node for the item resolved from the equal items
+// a, from the receiver, and b, from the other treap.
+func (t *Treap) resolved(a, b Item, priority int, left, right *node,
+	resolve func(a, b Item) Item) *node {
+	item := b
+	if resolve != nil {
+		if item = resolve(t.clone(a), t.clone(b)); item == nil {
+			item = a
+		}
+	}
+	return newNode(item, t.hashItem(item), priority, left, right)
+}
+
+// IntersectWith returns a treap of the receiver's items that are also
+// in the other treap, in O(M lg(N/M)) for treaps of sizes M <= N.
+// Both treaps must be ordered by the same compare func.
+func (t *Treap) IntersectWith(other *Treap) *Treap {
+	if t == nil {
+		return nil
+	}
+	return t.withRoot(t.intersect(t.top(), other.top()))
+}
+
+// intersect keeps the nodes of this that have an equal item in that,
+// splitting by the root with the higher priority, as union does.
+func (t *Treap) intersect(this, that *node) *node {
+	if this == nil || that == nil {
+		return nil
+	}
+	if this.priority > that.priority {
+		left, middle, right := t.split(that, this.item)
+		l, r := t.intersect(this.left, left), t.intersect(this.right, right)
+		if middle == nil {
+			return t.join(l, r)
+		}
+		return this.with(l, r)
+	}
+	left, middle, right := t.split(this, that.item)
+	l, r := t.intersect(left, that.left), t.intersect(right, that.right)
+	if middle == nil {
+		return t.join(l, r)
+	}
+	return t.join(t.join(l, middle.with(nil, nil)), r)
+}
+
+// DifferenceWith returns a treap of the receiver's items that are not
+// in the other treap, in O(M lg(N/M)) for treaps of sizes M <= N,
+// rather than a Delete per item.  Both treaps must be ordered by the
+// same compare func.
+func (t *Treap) DifferenceWith(other *Treap) *Treap {
+	if t == nil {
+		return nil
+	}
+	return t.withRoot(t.difference(t.top(), other.top()))
+}
+
+// difference keeps the nodes of this that have no equal item in that.
+func (t *Treap) difference(this, that *node) *node {
+	if this == nil || that == nil {
+		return this
+	}
+	if this.priority > that.priority {
+		left, middle, right := t.split(that, this.item)
+		l, r := t.difference(this.left, left), t.difference(this.right, right)
+		if middle == nil {
+			return this.with(l, r)
+		}
+		return t.join(l, r)
+	}
+	left, _, right := t.split(this, that.item)
+	return t.join(t.difference(left, that.left), t.difference(right, that.right))
+}
+
+// SymmetricDifferenceWith returns a treap of the items in exactly one
+// of the treaps, like the churn between two versions of a set, in
+// O(M lg(N/M)) for treaps of sizes M <= N.  Both treaps must be
+// ordered by the same compare func; the result uses the receiver's
+// compare func and options.
+func (t *Treap) SymmetricDifferenceWith(other *Treap) *Treap {
+	if t == nil {
+		return other
+	}
+	return t.withRoot(t.symmetricDifference(t.top(), other.top()))
+}
+
+// symmetricDifference drops both nodes of each pair of equal items.
+func (t *Treap) symmetricDifference(this, that *node) *node {
+	if this == nil {
+		return that
+	}
+	if that == nil {
+		return this
+	}
+	if this.priority > that.priority {
+		left, middle, right := t.split(that, this.item)
+		l, r := t.symmetricDifference(this.left, left),
+			t.symmetricDifference(this.right, right)
+		if middle == nil {
+			return this.with(l, r)
+		}
+		return t.join(l, r)
+	}
+	left, middle, right := t.split(this, that.item)
+	l, r := t.symmetricDifference(left, that.left),
+		t.symmetricDifference(right, that.right)
+	if middle == nil {
+		return that.with(l, r)
+	}
+	return t.join(l, r)
+}
+
+// IsSubsetOf returns whether every item of the receiver is in the
+// other treap.  Subtrees shared by the two treaps, as between versions
+// derived from each other, are not descended into, and a larger
+// subtree can't be a subset, so it is often much faster than probing
+// the other treap for each item.  Both treaps must be ordered by the
+// same compare func.
+func (t *Treap) IsSubsetOf(other *Treap) bool {
+	return t.subset(t.top(), other.top())
+}
+
+// IsSupersetOf returns whether every item of the other treap is in
+// the receiver, like other.IsSubsetOf(t).
+func (t *Treap) IsSupersetOf(other *Treap) bool {
+	return other.IsSubsetOf(t)
+}
+
+func (t *Treap) subset(this, that *node) bool {
+	if this == nil || this == that {
+		return true
+	}
+	if that == nil || this.size > that.size {
+		return false
+	}
+	left, middle, right := t.split(that, this.item)
+	return middle != nil && t.subset(this.left, left) && t.subset(this.right, right)
+}
@@ -0,0 +1,225 @@
+package gtreap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnionWith(t *testing.T) {
+	x := load(NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100}),
+		[]string{"a", "c", "e", "g"})
+	y := load(NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100}),
+		[]string{"b", "c", "d", "g", "h"})
+
+	var resolved [][2]Item
+	z := x.UnionWith(y, func(a, b Item) Item {
+		resolved = append(resolved, [2]Item{a, b})
+		return a
+	})
+	if !reflect.DeepEqual(z.Items(), []Item{"a", "b", "c", "d", "e", "g", "h"}) {
+		t.Errorf("expected the union, got: %v", z.Items())
+	}
+	if len(resolved) != 2 {
+		t.Errorf("expected c and g to be resolved, got: %v", resolved)
+	}
+	checkSizes(t, z.root)
+	checkHeap(t, z.root)
+	if z.ItemsSize() != 7 {
+		t.Errorf("expected items size 7, got: %v", z.ItemsSize())
+	}
+	if x.Size() != 4 || y.Size() != 5 {
+		t.Errorf("expected the original treaps to be unchanged")
+	}
+
+	if e := NewTreap(stringCompare); e.UnionWith(x, nil).Size() != 4 ||
+		x.UnionWith(e, nil).Size() != 4 {
+		t.Errorf("expected a union with an empty treap to keep the items")
+	}
+}
+
+func TestUnionWithResolve(t *testing.T) {
+	c := func(a, b interface{}) int {
+		return a.([2]int)[0] - b.([2]int)[0]
+	}
+	x, y := NewTreap(c), NewTreap(c)
+	for i := 0; i < 100; i++ {
+		x = x.Upsert([2]int{i, 1}, (i*7919)%1000)
+	}
+	for i := 50; i < 200; i += 2 {
+		y = y.Upsert([2]int{i, 10}, (i*31)%1000)
+	}
+	z := x.UnionWith(y, func(a, b Item) Item {
+		return [2]int{a.([2]int)[0], a.([2]int)[1] + b.([2]int)[1]}
+	})
+	if z.Size() != 150 {
+		t.Errorf("expected 150 items, got: %v", z.Size())
+	}
+	checkSizes(t, z.root)
+	checkHeap(t, z.root)
+	z.VisitAscend([2]int{0, 0}, func(i Item) bool {
+		k, v := i.([2]int)[0], i.([2]int)[1]
+		exp := 1
+		if k >= 100 {
+			exp = 10
+		} else if k >= 50 && k%2 == 0 {
+			exp = 11
+		}
+		if v != exp {
+			t.Errorf("key: %v, expected: %v, got: %v", k, exp, v)
+		}
+		return true
+	})
+
+	// Without resolve, the other's item is kept.
+	if z = x.UnionWith(y, nil); z.Get([2]int{50, 0}).([2]int)[1] != 10 {
+		t.Errorf("expected the other's item, got: %v", z.Get([2]int{50, 0}))
+	}
+}
+
+func TestIntersectWith(t *testing.T) {
+	x := load(NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100}),
+		[]string{"a", "c", "e", "g", "h"})
+	y := load(NewTreap(stringCompare), []string{"b", "c", "d", "g", "h", "i"})
+	z := x.IntersectWith(y)
+	if !reflect.DeepEqual(z.Items(), []Item{"c", "g", "h"}) {
+		t.Errorf("expected the intersection, got: %v", z.Items())
+	}
+	checkSizes(t, z.root)
+	checkHeap(t, z.root)
+	if z.ItemsSize() != 3 {
+		t.Errorf("expected items size 3, got: %v", z.ItemsSize())
+	}
+	if x.Size() != 5 || y.Size() != 6 {
+		t.Errorf("expected the original treaps to be unchanged")
+	}
+	if x.IntersectWith(NewTreap(stringCompare)).Size() != 0 {
+		t.Errorf("expected an empty intersection")
+	}
+
+	a, b := NewTreap(intCompare), NewTreap(intCompare)
+	for i := 0; i < 1000; i++ {
+		a = a.Upsert(i, (i*31)%1000)
+	}
+	for i := 0; i < 3000; i += 3 {
+		b = b.Upsert(i, (i*7919)%1000)
+	}
+	c := a.IntersectWith(b)
+	checkSizes(t, c.root)
+	checkHeap(t, c.root)
+	if c.Size() != 334 || c.Min() != 0 || c.Max() != 999 {
+		t.Errorf("expected the multiples of 3 below 1000, got: %v, %v, %v",
+			c.Size(), c.Min(), c.Max())
+	}
+}
+
+func TestDifferenceWith(t *testing.T) {
+	x := load(NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100}),
+		[]string{"a", "c", "e", "g", "h"})
+	y := load(NewTreap(stringCompare), []string{"b", "c", "d", "g", "h", "i"})
+	z := x.DifferenceWith(y)
+	if !reflect.DeepEqual(z.Items(), []Item{"a", "e"}) {
+		t.Errorf("expected the difference, got: %v", z.Items())
+	}
+	checkSizes(t, z.root)
+	checkHeap(t, z.root)
+	if z.ItemsSize() != 2 {
+		t.Errorf("expected items size 2, got: %v", z.ItemsSize())
+	}
+	if x.Size() != 5 || y.Size() != 6 {
+		t.Errorf("expected the original treaps to be unchanged")
+	}
+	if !reflect.DeepEqual(y.DifferenceWith(x).Items(), []Item{"b", "d", "i"}) {
+		t.Errorf("expected the reverse difference, got: %v", y.DifferenceWith(x).Items())
+	}
+	if x.DifferenceWith(nil).Size() != 5 || x.DifferenceWith(x).Size() != 0 {
+		t.Errorf("expected differences with empty and equal treaps")
+	}
+
+	a, b := NewTreap(intCompare), NewTreap(intCompare)
+	for i := 0; i < 1000; i++ {
+		a = a.Upsert(i, (i*31)%1000)
+	}
+	for i := 0; i < 3000; i += 3 {
+		b = b.Upsert(i, (i*7919)%1000)
+	}
+	c := a.DifferenceWith(b)
+	checkSizes(t, c.root)
+	checkHeap(t, c.root)
+	if c.Size() != 666 || c.Rank(3) != 2 || c.Get(999) != nil {
+		t.Errorf("expected the non-multiples of 3 below 1000, got: %v", c.Size())
+	}
+}
+
+func TestSymmetricDifferenceWith(t *testing.T) {
+	x := load(NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100}),
+		[]string{"a", "c", "e", "g", "h"})
+	y := load(NewTreap(stringCompare), []string{"b", "c", "d", "g", "h", "i"})
+	z := x.SymmetricDifferenceWith(y)
+	if !reflect.DeepEqual(z.Items(), []Item{"a", "b", "d", "e", "i"}) {
+		t.Errorf("expected the symmetric difference, got: %v", z.Items())
+	}
+	checkSizes(t, z.root)
+	checkHeap(t, z.root)
+	if z.ItemsSize() != 5 {
+		t.Errorf("expected items size 5, got: %v", z.ItemsSize())
+	}
+	if !reflect.DeepEqual(y.SymmetricDifferenceWith(x).Items(), z.Items()) {
+		t.Errorf("expected the symmetric difference to be symmetric")
+	}
+	if x.SymmetricDifferenceWith(x).Size() != 0 || x.SymmetricDifferenceWith(nil).Size() != 5 {
+		t.Errorf("expected symmetric differences with equal and empty treaps")
+	}
+	if x.Size() != 5 || y.Size() != 6 {
+		t.Errorf("expected the original treaps to be unchanged")
+	}
+
+	// Items sizes from a treap that does not track them are summed.
+	if u := x.UnionWith(y, nil); u.ItemsSize() != 8 {
+		t.Errorf("expected items size 8, got: %v", u.ItemsSize())
+	}
+}
+
+func TestIsSubsetOf(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"c", "g", "h"})
+	y := load(NewTreap(stringCompare), []string{"b", "c", "d", "g", "h", "i"})
+	tests := []struct {
+		a, b     *Treap
+		subset   bool
+		superset bool
+	}{
+		{x, y, true, false},
+		{y, x, false, true},
+		{x, x, true, true},
+		{x, x.Delete("g"), false, true},
+		{x.Upsert("a", 1), y, false, false},
+		{nil, x, true, false},
+		{x, nil, false, true},
+		{NewTreap(stringCompare), NewTreap(stringCompare), true, true},
+	}
+	for testIdx, test := range tests {
+		if got := test.a.IsSubsetOf(test.b); got != test.subset {
+			t.Errorf("test: %v, expected IsSubsetOf: %v, got: %v", testIdx, test.subset, got)
+		}
+		if got := test.a.IsSupersetOf(test.b); got != test.superset {
+			t.Errorf("test: %v, expected IsSupersetOf: %v, got: %v", testIdx, test.superset, got)
+		}
+	}
+
+	// Shared subtrees are not compared item by item.
+	compares := 0
+	a := NewTreap(func(a, b interface{}) int {
+		compares++
+		return a.(int) - b.(int)
+	})
+	for i := 0; i < 1000; i++ {
+		a = a.Upsert(i, (i*7919)%1000)
+	}
+	b := a.Upsert(1000, 500)
+	compares = 0
+	if !a.IsSubsetOf(b) || b.IsSubsetOf(a) {
+		t.Errorf("expected a to be a subset of b only")
+	}
+	if compares > 100 {
+		t.Errorf("expected shared subtrees to be skipped, got compares: %v", compares)
+	}
+}
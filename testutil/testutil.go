@@ -0,0 +1,95 @@
+// Package testutil generates deterministic item sets for tests and
+// benchmarks of gtreap, so performance reports can be reproduced.
+// The same Config always generates the same entries.
+package testutil
+
+import (
+	"math/rand"
+)
+
+// KeyDist selects the distribution of generated keys.
+type KeyDist int
+
+const (
+	Uniform    KeyDist = iota // Keys uniformly random in [0, KeyRange).
+	Zipf                      // Keys in [0, KeyRange) skewed towards 0.
+	Sequential                // Keys 0, 1, 2, ...
+)
+
+// PriorityDist selects how priorities are assigned to keys.
+type PriorityDist int
+
+const (
+	// RandomPriorities gives probabilistic O(lg N) tree heights.
+	RandomPriorities PriorityDist = iota
+
+	// AdversarialPriorities increase with the key, which degenerates
+	// a treap into a linked list of height N.
+	AdversarialPriorities
+)
+
+// Config describes an item set to generate.
+type Config struct {
+	Seed       int64
+	N          int // Number of entries.
+	Keys       KeyDist
+	KeyRange   int     // Upper bound of Uniform and Zipf keys, defaults to N.
+	ZipfS      float64 // Zipf skew, must be > 1, defaults to 1.1.
+	Priorities PriorityDist
+}
+
+// Entry is a generated key and its priority, as would be passed to
+// Treap.Upsert.
+type Entry struct {
+	Key      int
+	Priority int
+}
+
+// Generate returns the entries described by the config.  Uniform and
+// Zipf keys can repeat.
+func Generate(c Config) []Entry {
+	r := rand.New(rand.NewSource(c.Seed))
+	keyRange := c.KeyRange
+	if keyRange <= 0 {
+		keyRange = c.N
+	}
+	var zipf *rand.Zipf
+	if c.Keys == Zipf && keyRange > 0 {
+		s := c.ZipfS
+		if s <= 1 {
+			s = 1.1
+		}
+		zipf = rand.NewZipf(r, s, 1, uint64(keyRange-1))
+	}
+
+	res := make([]Entry, c.N)
+	for i := range res {
+		var k int
+		switch c.Keys {
+		case Uniform:
+			k = r.Intn(keyRange)
+		case Zipf:
+			k = int(zipf.Uint64())
+		case Sequential:
+			k = i
+		}
+		p := r.Int()
+		if c.Priorities == AdversarialPriorities {
+			p = k
+		}
+		res[i] = Entry{Key: k, Priority: p}
+	}
+	return res
+}
+
+// IntCompare compares int items, for use as a gtreap.Compare.
+func IntCompare(a, b interface{}) int {
+	x, y := a.(int), b.(int)
+	if x < y {
+		return -1
+	}
+	if x > y {
+		return 1
+	}
+	return 0
+}
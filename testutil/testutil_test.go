@@ -0,0 +1,54 @@
+package testutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	c := Config{Seed: 1, N: 1000, Keys: Uniform, KeyRange: 100}
+	a, b := Generate(c), Generate(c)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("expected the same config to generate the same entries")
+	}
+	c.Seed = 2
+	if reflect.DeepEqual(a, Generate(c)) {
+		t.Errorf("expected a different seed to generate different entries")
+	}
+	for _, e := range a {
+		if e.Key < 0 || e.Key >= 100 {
+			t.Fatalf("expected uniform keys in range, got: %v", e.Key)
+		}
+	}
+
+	seq := Generate(Config{N: 10, Keys: Sequential, Priorities: AdversarialPriorities})
+	for i, e := range seq {
+		if e.Key != i || e.Priority != i {
+			t.Errorf("expected sequential adversarial entry %v, got: %v", i, e)
+		}
+	}
+
+	zipf := Generate(Config{Seed: 1, N: 10000, Keys: Zipf, KeyRange: 1000})
+	low := 0
+	for _, e := range zipf {
+		if e.Key < 0 || e.Key >= 1000 {
+			t.Fatalf("expected zipf keys in range, got: %v", e.Key)
+		}
+		if e.Key < 10 {
+			low++
+		}
+	}
+	if low < len(zipf)/4 {
+		t.Errorf("expected zipf keys to be skewed to small keys, got: %v", low)
+	}
+
+	if len(Generate(Config{N: 0, Keys: Zipf})) != 0 {
+		t.Errorf("expected no entries")
+	}
+}
+
+func TestIntCompare(t *testing.T) {
+	if IntCompare(1, 2) != -1 || IntCompare(2, 1) != 1 || IntCompare(3, 3) != 0 {
+		t.Errorf("unexpected IntCompare results")
+	}
+}
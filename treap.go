@@ -1,8 +1,96 @@
 package gtreap
 
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+)
+
+// Treap is an immutable treap, where every write returns a new
+// version sharing structure with the old one.
+//
+// Nil is never an item, so the compare func is never invoked with a
+// nil item.  Writes of a nil item leave the treap unchanged, with
+// TryUpsert and Builder.Load returning ErrNilItem, a nil result from
+// a merge or resolve func keeps the existing item, and SortInto and
+// FromSyncMap skip nil items.  Lookups of a nil target, like Get,
+// Delete, Floor or Cursor.Seek, find nothing, and Rank and Split
+// treat nil as less than every item.  A nil pivot or bound of a visit
+// is unbounded, as in Query, so VisitAscend(nil, v) and
+// VisitDescend(nil, v) visit every item.
+//
+// A nil *Treap is treated as empty by reads.  Writes of a nil *Treap
+// return nil, as there is no compare func to build a treap with, and
+// TryUpsert returns ErrNilTreap.
 type Treap struct {
 	compare Compare
 	root    *node
+	options *Options
+}
+
+// Options configure optional behaviors of a Treap.  The zero value
+// gives the same behavior as NewTreap.
+type Options struct {
+	// CloneItems, when true, means items that implement Cloner are
+	// copied on Upsert, and copies are returned from Get, Min, Max
+	// and visitor callbacks, so callers that mutate items in place
+	// cannot corrupt the ordering of the treap.
+	CloneItems bool
+
+	// MaxItemSize, when > 0, is the largest approximate item size
+	// accepted by TryUpsert.  See ItemSizer.
+	MaxItemSize int
+
+	// MaxTotalSize, when > 0, is the largest approximate total size
+	// of all items that TryUpsert will allow the treap to reach.
+	MaxTotalSize int
+
+	// Normalize, when non-nil, is invoked on every item passed to
+	// Upsert or TryUpsert, and the returned item is stored instead,
+	// so that inputs like differently cased keys cannot end up as
+	// distinct items.  An error from Normalize is returned by
+	// TryUpsert, while Upsert leaves the treap unchanged.  Lookup
+	// targets of Get, Delete, etc, are not normalized.
+	Normalize func(Item) (Item, error)
+
+	// MaxDepth, when > 0, is the deepest recursion that TryUpsert and
+	// TryDelete will go into before returning an error instead, as a
+	// safety net against stack exhaustion when adversarial priorities
+	// have degenerated the treap into a very deep tree.
+	MaxDepth int
+
+	// HardenPriorities, when true, mixes every priority passed to
+	// Upsert with a secret, random per-treap salt before use, so that
+	// callers whose priorities derive from external input cannot
+	// intentionally degenerate the treap.  Relative priorities are
+	// then no longer meaningful, but remain deterministic per treap.
+	HardenPriorities bool
+
+	// Hash, when non-nil, returns a hash of an item's contents, used
+	// to maintain Fingerprint.  It is called once per Upsert'ed item.
+	Hash func(Item) uint64
+
+	salt uint64 // Secret for HardenPriorities.
+}
+
+// ItemSizer is an optional interface for items, used to compute the
+// approximate item sizes checked against MaxItemSize and
+// MaxTotalSize, and summed by ItemsSize.  Items that are a []byte
+// or string are sized by their length.  Other items are treated as
+// size 0.
+type ItemSizer interface {
+	ItemSize() int
+}
+
+func itemSize(i Item) int {
+	switch x := i.(type) {
+	case ItemSizer:
+		return x.ItemSize()
+	case []byte:
+		return len(x)
+	case string:
+		return len(x)
+	}
+	return 0
 }
 
 // Compare returns an integer comparing the two items
@@ -13,61 +101,555 @@ type Compare func(a, b interface{}) int
 // Item can be anything.
 type Item interface{}
 
+// Cloner is an optional interface for items, used when
+// Options.CloneItems is enabled.  Clone should return a deep enough
+// copy of the item that mutations of the copy do not affect the
+// original.
+type Cloner interface {
+	Clone() Item
+}
+
 type node struct {
 	item     Item
 	priority int
 	left     *node
 	right    *node
+
+	// Number of items in this subtree.
+	size int
+
+	// Sum of the Options.Hash of the items in this subtree.
+	hash uint64
+
+	// Sum of the approximate sizes of the items in this subtree, see
+	// ItemSizer.
+	bytes int
+}
+
+// newNode returns a node with its augmented fields computed from its
+// children, where itemHash is the Options.Hash of the item.
+func newNode(item Item, itemHash uint64, priority int, left, right *node) *node {
+	return withChildren(item, itemHash, itemSize(item), priority, left, right)
+}
+
+// with returns a copy of n with different children.
+func (n *node) with(left, right *node) *node {
+	return withChildren(n.item, n.itemHash(), n.itemBytes(), n.priority, left, right)
+}
+
+func withChildren(item Item, itemHash uint64, itemBytes int, priority int,
+	left, right *node) *node {
+	return &node{
+		item:     item,
+		priority: priority,
+		left:     left,
+		right:    right,
+		size:     1 + left.subtreeSize() + right.subtreeSize(),
+		hash:     itemHash + left.subtreeHash() + right.subtreeHash(),
+		bytes:    itemBytes + left.subtreeBytes() + right.subtreeBytes(),
+	}
+}
+
+func (n *node) subtreeSize() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *node) subtreeHash() uint64 {
+	if n == nil {
+		return 0
+	}
+	return n.hash
+}
+
+// itemHash recovers the hash of just the item of n, without having
+// to call Options.Hash again.
+func (n *node) itemHash() uint64 {
+	return n.hash - n.left.subtreeHash() - n.right.subtreeHash()
+}
+
+func (n *node) subtreeBytes() int {
+	if n == nil {
+		return 0
+	}
+	return n.bytes
+}
+
+// itemBytes recovers the size of just the item of n, like itemHash.
+func (n *node) itemBytes() int {
+	return n.bytes - n.left.subtreeBytes() - n.right.subtreeBytes()
 }
 
 func NewTreap(c Compare) *Treap {
 	return &Treap{compare: c, root: nil}
 }
 
+// NewTreapWithOptions is like NewTreap, but with optional behaviors.
+func NewTreapWithOptions(c Compare, o Options) *Treap {
+	if o.HardenPriorities {
+		var b [8]byte
+		if _, err := crand.Read(b[:]); err != nil {
+			panic("gtreap: could not read random salt: " + err.Error())
+		}
+		o.salt = binary.LittleEndian.Uint64(b[:])
+	}
+	return &Treap{compare: c, root: nil, options: &o}
+}
+
+// withRoot returns a new treap version that shares the compare func
+// and options of t.
+func (t *Treap) withRoot(r *node) *Treap {
+	return &Treap{compare: t.compare, root: r, options: t.options}
+}
+
+// top returns the root of the treap, or nil for a nil *Treap.
+func (t *Treap) top() *node {
+	if t == nil {
+		return nil
+	}
+	return t.root
+}
+
+// clone copies an item passing into or out of the treap, if the
+// CloneItems option is enabled and the item supports it.
+func (t *Treap) clone(i Item) Item {
+	if t == nil || t.options == nil || !t.options.CloneItems {
+		return i
+	}
+	if c, ok := i.(Cloner); ok {
+		return c.Clone()
+	}
+	return i
+}
+
 func (t *Treap) Min() Item {
-	n := t.root
+	n := t.top()
 	if n == nil {
 		return nil
 	}
 	for n.left != nil {
 		n = n.left
 	}
-	return n.item
+	return t.clone(n.item)
 }
 
 func (t *Treap) Max() Item {
-	n := t.root
+	n := t.top()
 	if n == nil {
 		return nil
 	}
 	for n.right != nil {
 		n = n.right
 	}
-	return n.item
+	return t.clone(n.item)
 }
 
 func (t *Treap) Get(target Item) Item {
-	n := t.root
+	n := t.find(target)
+	if n == nil {
+		return nil
+	}
+	return t.clone(n.item)
+}
+
+// GetPriority returns the priority stored with an item equal to the
+// target, and false if there is none.  With HardenPriorities, this
+// is the mixed priority, not the one passed to Upsert.
+func (t *Treap) GetPriority(target Item) (int, bool) {
+	n := t.find(target)
+	if n == nil {
+		return 0, false
+	}
+	return n.priority, true
+}
+
+func (t *Treap) find(target Item) *node {
+	n := t.top()
+	if n == nil || target == nil {
+		return nil
+	}
+	compare := t.compare
 	for n != nil {
-		c := t.compare(target, n.item)
+		c := compare(target, n.item)
 		if c < 0 {
 			n = n.left
 		} else if c > 0 {
 			n = n.right
 		} else {
-			return n.item
+			return n
 		}
 	}
 	return nil
 }
 
+// Size returns the number of items in the treap, in O(1).
+func (t *Treap) Size() int {
+	return t.top().subtreeSize()
+}
+
+// Rank returns the number of items less than the given item, in
+// O(lg N), whether or not the item is in the treap.
+func (t *Treap) Rank(item Item) int {
+	rank := 0
+	n := t.top()
+	for n != nil && item != nil {
+		c := t.compare(item, n.item)
+		if c <= 0 {
+			if c == 0 {
+				return rank + n.left.subtreeSize()
+			}
+			n = n.left
+		} else {
+			rank += n.left.subtreeSize() + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+// CountRange returns the number of items greater-than-or-equal to
+// low and less than high, in O(lg N) with two Rank's rather than
+// visiting the range.  A nil low or high is unbounded, like
+// VisitRange.
+func (t *Treap) CountRange(low, high Item) int {
+	hi := t.Size()
+	if high != nil {
+		hi = t.Rank(high)
+	}
+	if n := hi - t.Rank(low); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// Select returns the k-th smallest item, counting from 0, in O(lg N),
+// or nil if k is out of range.
+func (t *Treap) Select(k int) Item {
+	n := t.top()
+	for n != nil {
+		leftSize := n.left.subtreeSize()
+		if k < leftSize {
+			n = n.left
+		} else if k > leftSize {
+			k -= leftSize + 1
+			n = n.right
+		} else {
+			return t.clone(n.item)
+		}
+	}
+	return nil
+}
+
+// Fingerprint returns a hash of the treap's contents, computed from
+// Options.Hash of every item and kept up to date on each operation,
+// so it is O(1).  Treaps with equal items have equal fingerprints
+// regardless of their shape, so polling consumers can cheaply detect
+// if anything has changed.  It is always 0 without Options.Hash.
+func (t *Treap) Fingerprint() uint64 {
+	return t.top().subtreeHash()
+}
+
+// Floor returns the greatest item less-than-or-equal to the target,
+// or nil if there is none.
+func (t *Treap) Floor(target Item) Item {
+	var res *node
+	n := t.top()
+	for n != nil && target != nil {
+		c := t.compare(target, n.item)
+		if c < 0 {
+			n = n.left
+		} else if c > 0 {
+			res, n = n, n.right
+		} else {
+			res = n
+			break
+		}
+	}
+	if res == nil {
+		return nil
+	}
+	return t.clone(res.item)
+}
+
+// Ceiling returns the smallest item greater-than-or-equal to the
+// target, or nil if there is none.
+func (t *Treap) Ceiling(target Item) Item {
+	var res *node
+	n := t.top()
+	for n != nil && target != nil {
+		c := t.compare(target, n.item)
+		if c < 0 {
+			res, n = n, n.left
+		} else if c > 0 {
+			n = n.right
+		} else {
+			res = n
+			break
+		}
+	}
+	if res == nil {
+		return nil
+	}
+	return t.clone(res.item)
+}
+
+// Predecessor returns the greatest item less than the target, or nil
+// if there is none, whether or not the target is in the treap.
+func (t *Treap) Predecessor(target Item) Item {
+	var res *node
+	n := t.top()
+	for n != nil && target != nil {
+		if t.compare(target, n.item) > 0 {
+			res, n = n, n.right
+		} else {
+			n = n.left
+		}
+	}
+	if res == nil {
+		return nil
+	}
+	return t.clone(res.item)
+}
+
+// Successor returns the smallest item greater than the target, or nil
+// if there is none, whether or not the target is in the treap.
+func (t *Treap) Successor(target Item) Item {
+	var res *node
+	n := t.top()
+	for n != nil && target != nil {
+		if t.compare(target, n.item) < 0 {
+			res, n = n, n.left
+		} else {
+			n = n.right
+		}
+	}
+	if res == nil {
+		return nil
+	}
+	return t.clone(res.item)
+}
+
+// Neighbors returns both the Predecessor and Successor of the target.
+func (t *Treap) Neighbors(target Item) (Item, Item) {
+	return t.Predecessor(target), t.Successor(target)
+}
+
+// ItemsSize returns the approximate total size of the items in the
+// treap, in O(1).  See ItemSizer.
+func (t *Treap) ItemsSize() int {
+	return t.top().subtreeBytes()
+}
+
+func (t *Treap) limitsItemsSize() bool {
+	return t != nil && t.options != nil && t.options.MaxTotalSize > 0
+}
+
 // Note: only the priority of the first insert of an item is used.
 // Priorities from future updates on already existing items are
-// ignored.  To change the priority for an item, you need to do a
-// Delete then an Upsert.
+// ignored.  To change the priority for an item, use UpdatePriority.
 func (t *Treap) Upsert(item Item, itemPriority int) *Treap {
-	r := t.union(t.root, &node{item: item, priority: itemPriority})
-	return &Treap{compare: t.compare, root: r}
+	item, err := t.normalize(item)
+	if err != nil {
+		return t
+	}
+	return t.upsert(item, itemPriority)
+}
+
+// UpdatePriority returns a treap where the existing item equal to the
+// given item has a new priority, with the treap rebalanced around it,
+// in one split and two joins rather than a Delete then an Upsert.
+// When there is no equal item, the same treap is returned.
+func (t *Treap) UpdatePriority(item Item, newPriority int) *Treap {
+	if item == nil {
+		return t
+	}
+	left, middle, right := t.split(t.top(), item)
+	if middle == nil {
+		return t
+	}
+	n := newNode(middle.item, middle.itemHash(), t.priority(newPriority), nil, nil)
+	return t.withRoot(t.join(t.join(left, n), right))
+}
+
+// UpsertEx is like Upsert, but also returns the previous item that
+// was replaced, and whether there was one, in the same descent.
+func (t *Treap) UpsertEx(item Item, itemPriority int) (*Treap, Item, bool) {
+	item, err := t.normalize(item)
+	if err != nil {
+		return t, nil, false
+	}
+	var prev Item
+	replaced := false
+	res := t.upsertWith(item, itemPriority, func(existing, item Item) Item {
+		prev, replaced = existing, true
+		return item
+	})
+	return res, prev, replaced
+}
+
+// UpsertWith is like Upsert, but when an equal item already exists,
+// the item that is stored is the result of merge(existing, item),
+// which is useful for counters or sets of values.  The merged item
+// must compare equal to the existing item.  When merge returns nil,
+// the treap is left unchanged.
+func (t *Treap) UpsertWith(item Item, itemPriority int,
+	merge func(existing, item Item) Item) *Treap {
+	item, err := t.normalize(item)
+	if err != nil {
+		return t
+	}
+	kept := false
+	res := t.upsertWith(item, itemPriority, func(existing, item Item) Item {
+		if item = merge(existing, item); item == nil {
+			kept = true
+		}
+		return item
+	})
+	if kept {
+		return t
+	}
+	return res
+}
+
+// upsertWith is like upsert, but resolves an equal item with resolve,
+// in the one descent of unionWith.
+func (t *Treap) upsertWith(item Item, itemPriority int,
+	resolve func(existing, item Item) Item) *Treap {
+	item = t.clone(item)
+	return t.withRoot(t.unionWith(t.top(), newNode(item, t.hashItem(item),
+		t.priority(itemPriority), nil, nil), resolve))
+}
+
+// InsertIfAbsent is like Upsert, but only when there is no equal item
+// already, returning whether the item was inserted.  When not, the
+// same treap is returned.
+func (t *Treap) InsertIfAbsent(item Item, itemPriority int) (*Treap, bool) {
+	item, err := t.normalize(item)
+	if err != nil {
+		return t, false
+	}
+	item = t.clone(item)
+	n, inserted := t.insert(t.top(), newNode(item, t.hashItem(item),
+		t.priority(itemPriority), nil, nil))
+	if !inserted {
+		return t, false
+	}
+	return t.withRoot(n), true
+}
+
+// insert is like union of a treap with the single node that, but
+// stops on an equal item, returning false for nothing inserted.
+func (t *Treap) insert(this, that *node) (*node, bool) {
+	if this == nil {
+		return that, true
+	}
+	if this.priority > that.priority {
+		c := t.compare(that.item, this.item)
+		if c == 0 {
+			return this, false
+		}
+		if c < 0 {
+			left, inserted := t.insert(this.left, that)
+			if !inserted {
+				return this, false
+			}
+			return this.with(left, this.right), true
+		}
+		right, inserted := t.insert(this.right, that)
+		if !inserted {
+			return this, false
+		}
+		return this.with(this.left, right), true
+	}
+	left, middle, right := t.split(this, that.item)
+	if middle != nil {
+		return this, false
+	}
+	return that.with(left, right), true
+}
+
+// TryUpsert is like Upsert, but returns a *LimitError wrapping
+// ErrLimitExceeded, leaving the treap unchanged, if the item is
+// larger than Options.MaxItemSize or if the treap would grow beyond
+// Options.MaxTotalSize, or if its recursion would go deeper than
+// Options.MaxDepth.  It also returns any error from Options.Normalize.
+func (t *Treap) TryUpsert(item Item, itemPriority int) (*Treap, error) {
+	item, err := t.normalize(item)
+	if err != nil {
+		return t, err
+	}
+	if t.options != nil {
+		if err := t.checkDepth(item); err != nil {
+			return t, err
+		}
+		size := itemSize(item)
+		if t.options.MaxItemSize > 0 && size > t.options.MaxItemSize {
+			return t, &LimitError{
+				Limit: "MaxItemSize", Size: size, Max: t.options.MaxItemSize,
+			}
+		}
+		if t.limitsItemsSize() {
+			if itemsSize := t.itemsSizeAfterUpsert(item); itemsSize > t.options.MaxTotalSize {
+				return t, &LimitError{
+					Limit: "MaxTotalSize", Size: itemsSize, Max: t.options.MaxTotalSize,
+				}
+			}
+		}
+	}
+	return t.upsert(item, itemPriority), nil
+}
+
+// priority returns the priority to actually use for a priority
+// passed in by a caller, according to Options.HardenPriorities.
+func (t *Treap) priority(p int) int {
+	if t.options == nil || !t.options.HardenPriorities {
+		return p
+	}
+	// The splitmix64 finalizer.
+	x := uint64(p) ^ t.options.salt
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return int(x ^ (x >> 31))
+}
+
+func (t *Treap) hashItem(item Item) uint64 {
+	if t.options == nil || t.options.Hash == nil {
+		return 0
+	}
+	return t.options.Hash(item)
+}
+
+func (t *Treap) normalize(item Item) (Item, error) {
+	if t == nil {
+		return nil, ErrNilTreap
+	}
+	if item == nil {
+		return nil, ErrNilItem
+	}
+	if t.options == nil || t.options.Normalize == nil {
+		return item, nil
+	}
+	item, err := t.options.Normalize(item)
+	if err == nil && item == nil {
+		err = ErrNilItem
+	}
+	return item, err
+}
+
+func (t *Treap) upsert(item Item, itemPriority int) *Treap {
+	item = t.clone(item)
+	return t.withRoot(t.union(t.top(), newNode(item, t.hashItem(item),
+		t.priority(itemPriority), nil, nil)))
+}
+
+func (t *Treap) itemsSizeAfterUpsert(item Item) int {
+	res := t.root.subtreeBytes() + itemSize(item)
+	if n := t.find(item); n != nil {
+		res -= itemSize(n.item)
+	}
+	return res
 }
 
 func (t *Treap) union(this *node, that *node) *node {
@@ -80,28 +662,14 @@ func (t *Treap) union(this *node, that *node) *node {
 	if this.priority > that.priority {
 		left, middle, right := t.split(that, this.item)
 		if middle == nil {
-			return &node{
-				item:     this.item,
-				priority: this.priority,
-				left:     t.union(this.left, left),
-				right:    t.union(this.right, right),
-			}
-		}
-		return &node{
-			item:     middle.item,
-			priority: this.priority,
-			left:     t.union(this.left, left),
-			right:    t.union(this.right, right),
+			return this.with(t.union(this.left, left), t.union(this.right, right))
 		}
+		return newNode(middle.item, middle.itemHash(), this.priority,
+			t.union(this.left, left), t.union(this.right, right))
 	}
 	// We don't use middle because the "that" has precendence.
 	left, _, right := t.split(this, that.item)
-	return &node{
-		item:     that.item,
-		priority: that.priority,
-		left:     t.union(left, that.left),
-		right:    t.union(right, that.right),
-	}
+	return that.with(t.union(left, that.left), t.union(right, that.right))
 }
 
 // Splits a treap into two treaps based on a split item "s".
@@ -120,25 +688,226 @@ func (t *Treap) split(n *node, s Item) (*node, *node, *node) {
 	}
 	if c < 0 {
 		left, middle, right := t.split(n.left, s)
-		return left, middle, &node{
-			item:     n.item,
-			priority: n.priority,
-			left:     right,
-			right:    n.right,
-		}
+		return left, middle, n.with(right, n.right)
 	}
 	left, middle, right := t.split(n.right, s)
-	return &node{
-		item:     n.item,
-		priority: n.priority,
-		left:     n.left,
-		right:    left,
-	}, middle, right
+	return n.with(n.left, left), middle, right
 }
 
 func (t *Treap) Delete(target Item) *Treap {
-	left, _, right := t.split(t.root, target)
-	return &Treap{compare: t.compare, root: t.join(left, right)}
+	if t == nil || target == nil {
+		return t
+	}
+	left, _, right := t.split(t.top(), target)
+	return t.withRoot(t.join(left, right))
+}
+
+// DeleteEx is like Delete, but also returns the removed item and
+// whether the target was found, saving a separate Get.  When not
+// found, the same treap is returned.
+func (t *Treap) DeleteEx(target Item) (Item, bool, *Treap) {
+	if t == nil || target == nil {
+		return nil, false, t
+	}
+	left, middle, right := t.split(t.top(), target)
+	if middle == nil {
+		return nil, false, t
+	}
+	return t.clone(middle.item), true, t.withRoot(t.join(left, right))
+}
+
+// DeleteMin removes the smallest item in one pass, returning it along
+// with the new treap, or nil and the same treap if it is empty.
+func (t *Treap) DeleteMin() (Item, *Treap) {
+	if t.top() == nil {
+		return nil, t
+	}
+	removed, r := deleteMin(t.top())
+	return t.clone(removed.item), t.withRoot(r)
+}
+
+// DeleteMax removes the greatest item in one pass, returning it along
+// with the new treap, or nil and the same treap if it is empty.
+func (t *Treap) DeleteMax() (Item, *Treap) {
+	if t.top() == nil {
+		return nil, t
+	}
+	removed, r := deleteMax(t.top())
+	return t.clone(removed.item), t.withRoot(r)
+}
+
+// deleteMin returns the leftmost node of n, along with n without it.
+func deleteMin(n *node) (*node, *node) {
+	if n.left == nil {
+		return n, n.right
+	}
+	removed, left := deleteMin(n.left)
+	return removed, n.with(left, n.right)
+}
+
+func deleteMax(n *node) (*node, *node) {
+	if n.right == nil {
+		return n, n.left
+	}
+	removed, right := deleteMax(n.right)
+	return removed, n.with(n.left, right)
+}
+
+// TryDelete is like Delete, but returns a *LimitError wrapping
+// ErrLimitExceeded, leaving the treap unchanged, if its recursion
+// would go deeper than Options.MaxDepth.
+func (t *Treap) TryDelete(target Item) (*Treap, error) {
+	if err := t.checkDepth(target); err != nil {
+		return t, err
+	}
+	return t.Delete(target), nil
+}
+
+// checkDepth checks, with a loop rather than recursion, how deep an
+// Upsert or Delete of the item would recurse against
+// Options.MaxDepth.  Both recurse along the search path of the item,
+// and a Delete then joins the right spine of the found node's left
+// subtree with the left spine of its right subtree.  An Upsert of an
+// existing item is counted like a Delete, so the check is
+// conservative.
+func (t *Treap) checkDepth(item Item) error {
+	if t == nil || item == nil || t.options == nil || t.options.MaxDepth <= 0 {
+		return nil
+	}
+	depth := 0
+	n := t.top()
+	for n != nil {
+		depth++
+		c := t.compare(item, n.item)
+		if c < 0 {
+			n = n.left
+		} else if c > 0 {
+			n = n.right
+		} else {
+			for x := n.left; x != nil; x = x.right {
+				depth++
+			}
+			for x := n.right; x != nil; x = x.left {
+				depth++
+			}
+			break
+		}
+	}
+	if depth > t.options.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Size: depth, Max: t.options.MaxDepth}
+	}
+	return nil
+}
+
+// RetentionStats describes the items released by DeleteOlderThan.
+type RetentionStats struct {
+	Items int // Number of items released.
+	Bytes int // Approximate size of the released items, see ItemSizer.
+}
+
+// DeleteOlderThan returns a treap without the items that are less
+// than the pivot, along with stats on what was released.  This is the
+// usual retention operation for time-keyed treaps, done with one
+// split rather than one Delete per item, like DeleteLessThan, and
+// with the stats taken from the removed subtree in O(1).
+func (t *Treap) DeleteOlderThan(pivot Item) (*Treap, RetentionStats) {
+	res, removed := t.DeleteLessThan(pivot)
+	return res, RetentionStats{
+		Items: removed.Size(),
+		Bytes: removed.top().subtreeBytes(),
+	}
+}
+
+// DeleteLessThan returns a treap without the items less than the
+// pivot, in one split, along with a treap of the removed items.  A
+// nil pivot, like an unset watermark, removes nothing.
+func (t *Treap) DeleteLessThan(pivot Item) (res, removed *Treap) {
+	if t == nil {
+		return nil, nil
+	}
+	if pivot == nil {
+		return t, t.withRoot(nil)
+	}
+	left, middle, right := t.split(t.top(), pivot)
+	if middle != nil {
+		right = t.join(middle.with(nil, nil), right)
+	}
+	return t.withRoot(right), t.withRoot(left)
+}
+
+// DeleteGreaterThan returns a treap without the items greater than
+// the pivot, in one split, along with a treap of the removed items.
+// A nil pivot, like an unset watermark, removes nothing.
+func (t *Treap) DeleteGreaterThan(pivot Item) (res, removed *Treap) {
+	if t == nil {
+		return nil, nil
+	}
+	if pivot == nil {
+		return t, t.withRoot(nil)
+	}
+	left, middle, right := t.split(t.top(), pivot)
+	if middle != nil {
+		left = t.join(left, middle.with(nil, nil))
+	}
+	return t.withRoot(left), t.withRoot(right)
+}
+
+// DeleteRange returns a treap without the items greater-than-or-equal
+// to low and less than high, in two splits and a join, so O(lg N)
+// however many items are in the range.  A nil low or high is
+// unbounded, like VisitRange.
+func (t *Treap) DeleteRange(low, high Item) *Treap {
+	if t == nil || (low != nil && high != nil && t.compare(low, high) >= 0) {
+		return t
+	}
+	var left, right *node
+	rest := t.top()
+	if low != nil {
+		left, _, rest = t.split(rest, low)
+	}
+	if high != nil {
+		var last *node
+		_, last, right = t.split(rest, high)
+		if last != nil { // The high item itself is kept.
+			right = t.join(last.with(nil, nil), right)
+		}
+	}
+	return t.withRoot(t.join(left, right))
+}
+
+// Split partitions the treap at the item, returning a treap of the
+// items less than it, the equal item if any, or nil, and a treap of
+// the items greater than it.  The treaps share structure with the
+// original, which is unchanged.
+func (t *Treap) Split(item Item) (left *Treap, found Item, right *Treap) {
+	if t == nil {
+		return nil, nil, nil
+	}
+	if item == nil {
+		return t.withRoot(nil), nil, t
+	}
+	l, middle, r := t.split(t.top(), item)
+	left, right = t.withRoot(l), t.withRoot(r)
+	if middle != nil {
+		found = t.clone(middle.item)
+	}
+	return left, found, right
+}
+
+// Join returns a treap of the items of both treaps, all of whose
+// items must be less than those of the other treap, like the results
+// of Split, in O(lg N).  The result uses the receiver's compare func
+// and options.  The ordering is only checked, by panicking, when built
+// with the gtreap_debug tag.
+func (t *Treap) Join(other *Treap) *Treap {
+	if t == nil {
+		return other
+	}
+	if debug && t.top() != nil && other.top() != nil &&
+		t.compare(t.Max(), other.Min()) >= 0 {
+		panic("gtreap: Join of treaps that are not ordered")
+	}
+	return t.withRoot(t.join(t.top(), other.top()))
 }
 
 // All the items from this are < items from that.
@@ -150,39 +919,103 @@ func (t *Treap) join(this *node, that *node) *node {
 		return this
 	}
 	if this.priority > that.priority {
-		return &node{
-			item:     this.item,
-			priority: this.priority,
-			left:     this.left,
-			right:    t.join(this.right, that),
-		}
-	}
-	return &node{
-		item:     that.item,
-		priority: that.priority,
-		left:     t.join(this, that.left),
-		right:    that.right,
+		return this.with(this.left, t.join(this.right, that))
 	}
+	return that.with(t.join(this, that.left), that.right)
 }
 
 type ItemVisitor func(i Item) bool
 
-// Visit items greater-than-or-equal to the pivot.
+// Visit items greater-than-or-equal to the pivot, or every item for
+// a nil pivot.
 func (t *Treap) VisitAscend(pivot Item, visitor ItemVisitor) {
-	t.visitAscend(t.root, pivot, visitor)
+	// An explicit stack of the nodes still to be visited, rather than
+	// recursion, means the pivot is only compared along one path.
+	var buf [64]*node
+	stack := buf[:0]
+	n := t.top()
+	for n != nil {
+		if pivot == nil || t.compare(pivot, n.item) <= 0 {
+			stack = append(stack, n)
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	for len(stack) > 0 {
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visitor(t.clone(n.item)) {
+			return
+		}
+		for n = n.right; n != nil; n = n.left {
+			stack = append(stack, n)
+		}
+	}
 }
 
-func (t *Treap) visitAscend(n *node, pivot Item, visitor ItemVisitor) bool {
-	if n == nil {
-		return true
+// Visit items greater-than-or-equal to low and less than high, in
+// ascending order.  Subtrees beyond high are never descended into, so
+// this is O(lg N + K) to visit K items.  A nil low or high is
+// unbounded.
+func (t *Treap) VisitRange(low, high Item, visitor ItemVisitor) {
+	t.VisitAscend(low, func(i Item) bool {
+		return (high == nil || t.compare(i, high) < 0) && visitor(i)
+	})
+}
+
+// Visit items less-than-or-equal to the pivot, or every item for a
+// nil pivot, in descending order.
+func (t *Treap) VisitDescend(pivot Item, visitor ItemVisitor) {
+	var buf [64]*node
+	stack := buf[:0]
+	n := t.top()
+	for n != nil {
+		if pivot == nil || t.compare(pivot, n.item) >= 0 {
+			stack = append(stack, n)
+			n = n.right
+		} else {
+			n = n.left
+		}
 	}
-	if t.compare(pivot, n.item) <= 0 {
-		if !t.visitAscend(n.left, pivot, visitor) {
-			return false
+	for len(stack) > 0 {
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !visitor(t.clone(n.item)) {
+			return
 		}
-		if !visitor(n.item) {
-			return false
+		for n = n.left; n != nil; n = n.right {
+			stack = append(stack, n)
 		}
 	}
-	return t.visitAscend(n.right, pivot, visitor)
+}
+
+// visitAll visits every item of the subtree n in ascending order.
+func (t *Treap) visitAll(n *node, visitor ItemVisitor) bool {
+	if n == nil {
+		return true
+	}
+	if !t.visitAll(n.left, visitor) {
+		return false
+	}
+	if !visitor(t.clone(n.item)) {
+		return false
+	}
+	return t.visitAll(n.right, visitor)
+}
+
+// Range calls f on every item in ascending order, stopping if f
+// returns false, like sync.Map's Range.
+func (t *Treap) Range(f ItemVisitor) {
+	t.visitAll(t.top(), f)
+}
+
+// Items returns all the items of the treap, in ascending order.
+func (t *Treap) Items() []Item {
+	var res []Item
+	t.visitAll(t.top(), func(i Item) bool {
+		res = append(res, i)
+		return true
+	})
+	return res
 }
@@ -2,7 +2,17 @@ package gtreap
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"unsafe"
+
+	"github.com/steveyen/gtreap/testutil"
 )
 
 func stringCompare(a, b interface{}) int {
@@ -223,6 +233,592 @@ func TestVisitEndEarly(t *testing.T) {
 	visitX()
 }
 
+func visitDescendExpect(t *testing.T, x *Treap, start string, arr []string) {
+	n := 0
+	x.VisitDescend(start, func(i Item) bool {
+		if i.(string) != arr[n] {
+			t.Errorf("expected visit item: %v, saw: %v", arr[n], i)
+		}
+		n++
+		return true
+	})
+	if n != len(arr) {
+		t.Errorf("expected # visit callbacks: %v, saw: %v", len(arr), n)
+	}
+}
+
+func TestVisitDescend(t *testing.T) {
+	x := NewTreap(stringCompare)
+	visitDescendExpect(t, x, "a", []string{})
+
+	x = load(x, []string{"e", "d", "c", "c", "a", "b", "a"})
+
+	visitDescendExpect(t, x, "0", []string{})
+	visitDescendExpect(t, x, "a", []string{"a"})
+	visitDescendExpect(t, x, "a1", []string{"a"})
+	visitDescendExpect(t, x, "b", []string{"b", "a"})
+	visitDescendExpect(t, x, "c1", []string{"c", "b", "a"})
+	visitDescendExpect(t, x, "e", []string{"e", "d", "c", "b", "a"})
+	visitDescendExpect(t, x, "z", []string{"e", "d", "c", "b", "a"})
+
+	// Ending early.
+	n := 0
+	x.VisitDescend("z", func(i Item) bool {
+		n++
+		return i.(string) > "c"
+	})
+	if n != 3 {
+		t.Errorf("expected VisitDescend to stop after 3 callbacks, saw: %v", n)
+	}
+}
+
+func TestVisitRange(t *testing.T) {
+	x := NewTreap(stringCompare)
+	x = load(x, []string{"e", "d", "c", "a", "b", "f"})
+
+	tests := []struct {
+		low, high string
+		exp       []Item
+	}{
+		{"a", "z", []Item{"a", "b", "c", "d", "e", "f"}},
+		{"b", "e", []Item{"b", "c", "d"}},
+		{"b1", "d1", []Item{"c", "d"}},
+		{"c", "c", nil},
+		{"d", "c", nil},
+		{"g", "z", nil},
+		{"0", "a", nil},
+	}
+	for testIdx, test := range tests {
+		var got []Item
+		x.VisitRange(test.low, test.high, func(i Item) bool {
+			got = append(got, i)
+			return true
+		})
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("test: %v, expected: %v, got: %v", testIdx, test.exp, got)
+		}
+	}
+
+	var got []Item
+	x.VisitRange("a", "z", func(i Item) bool {
+		got = append(got, i)
+		return len(got) < 2
+	})
+	if !reflect.DeepEqual(got, []Item{"a", "b"}) {
+		t.Errorf("expected VisitRange to stop early, got: %v", got)
+	}
+}
+
+// checkSizes verifies the subtree sizes of n, returning its size.
+func checkSizes(t *testing.T, n *node) int {
+	if n == nil {
+		return 0
+	}
+	size := 1 + checkSizes(t, n.left) + checkSizes(t, n.right)
+	if n.size != size {
+		t.Errorf("expected subtree size: %v, got: %v", size, n.size)
+	}
+	if b := itemSize(n.item) + n.left.subtreeBytes() + n.right.subtreeBytes(); n.bytes != b {
+		t.Errorf("expected subtree bytes: %v, got: %v", b, n.bytes)
+	}
+	return size
+}
+
+// sizedItem is an int item that counts calls of its ItemSize.
+type sizedItem int
+
+var sizedItemCalls int
+
+func (i sizedItem) ItemSize() int {
+	sizedItemCalls++
+	return 10
+}
+
+func TestItemsSizeAugmented(t *testing.T) {
+	c := func(a, b interface{}) int { return int(a.(sizedItem)) - int(b.(sizedItem)) }
+	x := NewTreapWithOptions(c, Options{MaxTotalSize: 1 << 30})
+	y := NewTreap(c)
+	for i := 0; i < 1000; i++ {
+		x = x.Upsert(sizedItem(i), (i*7919)%1000)
+		y = y.Upsert(sizedItem(i+1000), (i*31)%1000)
+	}
+
+	// The sizes of the items are summed per subtree, so splitting and
+	// joining, even with a treap without limits, visits no items.
+	sizedItemCalls = 0
+	left, _, right := x.Split(sizedItem(300))
+	z := left.Join(right).Join(y)
+	l, _ := x.DeleteLessThan(sizedItem(500))
+	i := x.IntersectWith(l)
+	if sizedItemCalls != 0 {
+		t.Errorf("expected no items to be sized, got: %v", sizedItemCalls)
+	}
+	if left.ItemsSize() != 3000 || right.ItemsSize() != 6990 ||
+		z.ItemsSize() != 19990 || l.ItemsSize() != 5000 || i.ItemsSize() != 5000 {
+		t.Errorf("expected items sizes, got: %v, %v, %v, %v, %v", left.ItemsSize(),
+			right.ItemsSize(), z.ItemsSize(), l.ItemsSize(), i.ItemsSize())
+	}
+	checkSizes(t, z.root)
+}
+
+func TestSize(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if x.Size() != 0 {
+		t.Errorf("expected empty treap to have size 0")
+	}
+
+	y := load(x, []string{"e", "d", "c", "c", "a", "b", "a"})
+	if y.Size() != 5 || x.Size() != 0 {
+		t.Errorf("expected sizes 5 and 0, got: %v, %v", y.Size(), x.Size())
+	}
+	checkSizes(t, y.root)
+
+	z := y.Delete("c").Delete("not-there")
+	if z.Size() != 4 || y.Size() != 5 {
+		t.Errorf("expected sizes 4 and 5, got: %v, %v", z.Size(), y.Size())
+	}
+	checkSizes(t, z.root)
+
+	w, _ := y.DeleteOlderThan("c")
+	if w.Size() != 3 {
+		t.Errorf("expected size 3 after DeleteOlderThan, got: %v", w.Size())
+	}
+	checkSizes(t, w.root)
+
+	r := rand.New(rand.NewSource(1))
+	v := NewTreap(intCompare)
+	present := map[int]bool{}
+	for i := 0; i < 2000; i++ {
+		k := r.Intn(500)
+		if r.Intn(3) == 0 {
+			v = v.Delete(k)
+			delete(present, k)
+		} else {
+			v = v.Upsert(k, r.Int())
+			present[k] = true
+		}
+		if v.Size() != len(present) {
+			t.Fatalf("expected size: %v, got: %v", len(present), v.Size())
+		}
+	}
+	checkSizes(t, v.root)
+
+	b := NewBuilder(intCompare, Options{})
+	for i := 0; i < 100; i++ {
+		b.Load(i)
+	}
+	u := b.Treap()
+	if u.Size() != 100 {
+		t.Errorf("expected built size 100, got: %v", u.Size())
+	}
+	checkSizes(t, u.root)
+}
+
+func TestVisitAllocs(t *testing.T) {
+	x, _ := benchmarkTreap(1000)
+
+	// Closures capturing state must not escape the visit methods, or
+	// every scan would allocate.
+	var sum int
+	visitor := func(i Item) bool {
+		sum += i.(int)
+		return true
+	}
+	for name, scan := range map[string]func(){
+		"VisitAscend":  func() { x.VisitAscend(100, visitor) },
+		"VisitDescend": func() { x.VisitDescend(900, visitor) },
+		"VisitRange":   func() { x.VisitRange(100, 900, visitor) },
+		"Range":        func() { x.Range(visitor) },
+	} {
+		if allocs := testing.AllocsPerRun(10, scan); allocs != 0 {
+			t.Errorf("expected %v to not allocate, got: %v allocs", name, allocs)
+		}
+	}
+}
+
+func TestRankAndSelect(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if x.Rank("a") != 0 || x.Select(0) != nil {
+		t.Errorf("expected rank 0 and no items in an empty treap")
+	}
+
+	x = load(x, []string{"e", "b", "d", "a", "c"})
+	ranks := map[string]int{
+		"0": 0, "a": 0, "a1": 1, "b": 1, "c": 2, "c1": 3, "e": 4, "z": 5,
+	}
+	for item, exp := range ranks {
+		if got := x.Rank(item); got != exp {
+			t.Errorf("expected rank of %v: %v, got: %v", item, exp, got)
+		}
+	}
+	for k, exp := range []string{"a", "b", "c", "d", "e"} {
+		if got := x.Select(k); got != exp {
+			t.Errorf("expected select %v: %v, got: %v", k, exp, got)
+		}
+		if x.Rank(x.Select(k)) != k {
+			t.Errorf("expected rank of select %v to be %v", k, k)
+		}
+	}
+	if x.Select(-1) != nil || x.Select(5) != nil {
+		t.Errorf("expected nil for out of range selects")
+	}
+
+	y, _ := benchmarkTreap(1000)
+	for k := 0; k < y.Size(); k += 37 {
+		if y.Rank(y.Select(k)) != k {
+			t.Errorf("expected rank of select %v to be %v", k, k)
+		}
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"e", "b", "d", "a", "c"})
+	tests := []struct {
+		low, high Item
+		exp       int
+	}{
+		{"a", "c", 2},
+		{"a1", "c1", 2},
+		{"b", "b", 0},
+		{"d", "b", 0},
+		{"0", "z", 5},
+		{nil, "c", 2},
+		{"c", nil, 3},
+		{nil, nil, 5},
+	}
+	for _, test := range tests {
+		if got := x.CountRange(test.low, test.high); got != test.exp {
+			t.Errorf("[%v, %v), expected: %v, got: %v", test.low, test.high, test.exp, got)
+		}
+		count := 0
+		x.VisitRange(test.low, test.high, func(i Item) bool {
+			count++
+			return true
+		})
+		if count != test.exp {
+			t.Errorf("[%v, %v), expected VisitRange to agree, got: %v",
+				test.low, test.high, count)
+		}
+	}
+	if NewTreap(stringCompare).CountRange("a", "z") != 0 {
+		t.Errorf("expected no items in an empty treap")
+	}
+}
+
+func TestFloorAndCeiling(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if x.Floor("a") != nil || x.Ceiling("a") != nil {
+		t.Errorf("expected nil Floor and Ceiling in an empty treap")
+	}
+
+	x = load(x, []string{"d", "b", "f"})
+	tests := []struct {
+		target      string
+		floor, ceil Item
+	}{
+		{"a", nil, "b"},
+		{"b", "b", "b"},
+		{"c", "b", "d"},
+		{"d", "d", "d"},
+		{"e", "d", "f"},
+		{"f", "f", "f"},
+		{"g", "f", nil},
+	}
+	for _, test := range tests {
+		if got := x.Floor(test.target); got != test.floor {
+			t.Errorf("expected Floor(%v): %v, got: %v", test.target, test.floor, got)
+		}
+		if got := x.Ceiling(test.target); got != test.ceil {
+			t.Errorf("expected Ceiling(%v): %v, got: %v", test.target, test.ceil, got)
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if p, s := x.Neighbors("a"); p != nil || s != nil {
+		t.Errorf("expected no neighbors in an empty treap")
+	}
+
+	x = load(x, []string{"d", "b", "f"})
+	tests := []struct {
+		target     string
+		pred, succ Item
+	}{
+		{"a", nil, "b"},
+		{"b", nil, "d"},
+		{"c", "b", "d"},
+		{"d", "b", "f"},
+		{"e", "d", "f"},
+		{"f", "d", nil},
+		{"g", "f", nil},
+	}
+	for _, test := range tests {
+		if got := x.Predecessor(test.target); got != test.pred {
+			t.Errorf("expected Predecessor(%v): %v, got: %v", test.target, test.pred, got)
+		}
+		if got := x.Successor(test.target); got != test.succ {
+			t.Errorf("expected Successor(%v): %v, got: %v", test.target, test.succ, got)
+		}
+		if p, s := x.Neighbors(test.target); p != test.pred || s != test.succ {
+			t.Errorf("expected Neighbors(%v): %v, %v, got: %v, %v",
+				test.target, test.pred, test.succ, p, s)
+		}
+	}
+}
+
+func TestUpsertEx(t *testing.T) {
+	x := NewTreap(cloneItemCompare)
+	a1 := &cloneItem{key: "a", val: []byte("1")}
+	a2 := &cloneItem{key: "a", val: []byte("2")}
+
+	x, prev, replaced := x.UpsertEx(a1, 1)
+	if prev != nil || replaced {
+		t.Errorf("expected no previous item, got: %v, %v", prev, replaced)
+	}
+	y, prev, replaced := x.UpsertEx(a2, 2)
+	if prev != a1 || !replaced {
+		t.Errorf("expected the previous item, got: %v, %v", prev, replaced)
+	}
+	if y.Get(a1) != a2 || x.Get(a1) != a1 || y.Size() != 1 {
+		t.Errorf("expected the new item in the new treap only")
+	}
+}
+
+type counter struct {
+	key   string
+	count int
+}
+
+func TestUpsertWith(t *testing.T) {
+	compare := func(a, b interface{}) int {
+		return stringCompare(a.(*counter).key, b.(*counter).key)
+	}
+	sum := func(existing, item Item) Item {
+		return &counter{
+			key:   existing.(*counter).key,
+			count: existing.(*counter).count + item.(*counter).count,
+		}
+	}
+
+	x := NewTreap(compare)
+	for i, k := range []string{"a", "b", "a", "a", "c", "b"} {
+		x = x.UpsertWith(&counter{key: k, count: 1}, i, sum)
+	}
+	counts := map[string]int{}
+	x.Range(func(i Item) bool {
+		counts[i.(*counter).key] = i.(*counter).count
+		return true
+	})
+	if !reflect.DeepEqual(counts, map[string]int{"a": 3, "b": 2, "c": 1}) {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+// compareCalls counts the calls of countingCompare.
+var compareCalls int
+
+func countingCompare(a, b interface{}) int {
+	compareCalls++
+	return a.(int) - b.(int)
+}
+
+func TestUpsertOneDescent(t *testing.T) {
+	x := NewTreap(countingCompare)
+	for i := 0; i < 1000; i++ {
+		x = x.Upsert(i*2, (i*7919)%1000)
+	}
+	keep := func(existing, item Item) Item { return existing }
+	for _, k := range []int{-1, 0, 501, 998, 1000, 1999} {
+		compareCalls = 0
+		x.Upsert(k, 500)
+		upsert := compareCalls
+		compareCalls = 0
+		x.UpsertWith(k, 500, keep)
+		with := compareCalls
+		compareCalls = 0
+		x.UpsertEx(k, 500)
+		if with != upsert || compareCalls != upsert {
+			t.Errorf("expected %v compares for %v, got UpsertWith: %v, UpsertEx: %v",
+				upsert, k, with, compareCalls)
+		}
+		compareCalls = 0
+		y, inserted := x.InsertIfAbsent(k, 500)
+		if compareCalls > upsert || inserted != (k%2 != 0) || (y == x) == inserted {
+			t.Errorf("expected at most %v compares for InsertIfAbsent(%v), got: %v, %v",
+				upsert, k, compareCalls, inserted)
+		}
+		if z := x.Upsert(k, 500); !reflect.DeepEqual(y.Items(), z.Items()) {
+			t.Errorf("expected InsertIfAbsent(%v) to match Upsert", k)
+		}
+		checkSizes(t, y.root)
+	}
+}
+
+func TestInsertIfAbsent(t *testing.T) {
+	x := NewTreap(cloneItemCompare)
+	a1 := &cloneItem{key: "a", val: []byte("1")}
+	a2 := &cloneItem{key: "a", val: []byte("2")}
+
+	x, inserted := x.InsertIfAbsent(a1, 1)
+	if !inserted || x.Get(a1) != a1 {
+		t.Errorf("expected InsertIfAbsent to insert a missing item")
+	}
+	y, inserted := x.InsertIfAbsent(a2, 2)
+	if inserted || y != x || y.Get(a2) != a1 {
+		t.Errorf("expected InsertIfAbsent to keep an existing item")
+	}
+}
+
+func TestUpdatePriority(t *testing.T) {
+	x := NewTreap(stringCompare)
+	x = x.Upsert("m", 20)
+	x = x.Upsert("l", 18)
+	x = x.Upsert("n", 19)
+	if x.UpdatePriority("not-there", 100) != x {
+		t.Errorf("expected UpdatePriority of a missing item to be a no-op")
+	}
+
+	y := x.UpdatePriority("l", 30)
+	if y.root.item != "l" || y.root.priority != 30 {
+		t.Errorf("expected l to become the root, got: %v", y.root.item)
+	}
+	y = y.UpdatePriority("l", 1)
+	if y.root.item != "m" || y.find("l").priority != 1 {
+		t.Errorf("expected m to be the root again, got: %v", y.root.item)
+	}
+	checkHeap(t, y.root)
+	checkSizes(t, y.root)
+	if !reflect.DeepEqual(y.Items(), []Item{"l", "m", "n"}) {
+		t.Errorf("expected the same items, got: %v", y.Items())
+	}
+	if x.root.item != "m" {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+
+	z, _ := benchmarkTreap(1000)
+	for i := 0; i < 1000; i += 10 {
+		z = z.UpdatePriority(i, i)
+	}
+	checkHeap(t, z.root)
+	checkSizes(t, z.root)
+}
+
+func TestGetPriority(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if _, ok := x.GetPriority("a"); ok {
+		t.Errorf("expected no priority in an empty treap")
+	}
+	x = x.Upsert("m", 20).Upsert("l", 18).Upsert("n", 19)
+	if p, ok := x.GetPriority("l"); !ok || p != 18 {
+		t.Errorf("expected priority 18 for l, got: %v, %v", p, ok)
+	}
+	if _, ok := x.GetPriority("not-there"); ok {
+		t.Errorf("expected no priority for a missing item")
+	}
+	if p, _ := x.UpdatePriority("l", 30).GetPriority("l"); p != 30 {
+		t.Errorf("expected the updated priority, got: %v", p)
+	}
+}
+
+func TestDeleteEx(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"b", "a", "c"})
+
+	i, found, y := x.DeleteEx("b")
+	if i != "b" || !found || y.Size() != 2 || y.Get("b") != nil {
+		t.Errorf("expected DeleteEx to remove b, got: %v, %v", i, found)
+	}
+	checkSizes(t, y.root)
+
+	i, found, z := y.DeleteEx("b")
+	if i != nil || found || z != y {
+		t.Errorf("expected DeleteEx of a missing item to be a no-op")
+	}
+	if x.Size() != 3 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+}
+
+func TestDeleteMinMax(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if i, y := x.DeleteMin(); i != nil || y != x {
+		t.Errorf("expected DeleteMin of an empty treap to be a no-op")
+	}
+	if i, y := x.DeleteMax(); i != nil || y != x {
+		t.Errorf("expected DeleteMax of an empty treap to be a no-op")
+	}
+
+	x = NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100})
+	x = load(x, []string{"c", "a", "e", "b", "d"})
+	var got []Item
+	for y := x; y.Size() > 0; {
+		var i Item
+		i, y = y.DeleteMin()
+		got = append(got, i)
+		checkSizes(t, y.root)
+		if y.ItemsSize() != y.Size() {
+			t.Errorf("expected items size to track DeleteMin")
+		}
+	}
+	if !reflect.DeepEqual(got, []Item{"a", "b", "c", "d", "e"}) {
+		t.Errorf("unexpected DeleteMin order: %v", got)
+	}
+
+	got = nil
+	for y := x; y.Size() > 0; {
+		var i Item
+		i, y = y.DeleteMax()
+		got = append(got, i)
+		checkSizes(t, y.root)
+	}
+	if !reflect.DeepEqual(got, []Item{"e", "d", "c", "b", "a"}) {
+		t.Errorf("unexpected DeleteMax order: %v", got)
+	}
+	if x.Size() != 5 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+}
+
+func TestHardenPriorities(t *testing.T) {
+	height := func(x *Treap) int {
+		var h func(n *node) int
+		h = func(n *node) int {
+			if n == nil {
+				return 0
+			}
+			l, r := h(n.left), h(n.right)
+			if l > r {
+				return l + 1
+			}
+			return r + 1
+		}
+		return h(x.root)
+	}
+
+	// Adversarial priorities that increase with the items.
+	x := NewTreap(intCompare)
+	y := NewTreapWithOptions(intCompare, Options{HardenPriorities: true})
+	z := NewTreapWithOptions(intCompare, Options{HardenPriorities: true})
+	for i := 0; i < 1000; i++ {
+		x = x.Upsert(i, i)
+		y = y.Upsert(i, i)
+		z = z.Upsert(i, i)
+	}
+	if height(x) != 1000 {
+		t.Errorf("expected a degenerate treap without hardening, got: %v", height(x))
+	}
+	if h := height(y); h > 60 {
+		t.Errorf("expected a shallow treap with hardening, got height: %v", h)
+	}
+	if y.options.salt == z.options.salt {
+		t.Errorf("expected different salts per treap")
+	}
+	if y.Size() != 1000 || y.Get(500) != 500 {
+		t.Errorf("expected a hardened treap to hold all the items")
+	}
+	checkHeap(t, y.root)
+}
+
 func TestPriorityAfterUpsert(t *testing.T) {
 	// See https://github.com/steveyen/gtreap/issues/3 found by icexin.
 
@@ -257,3 +853,690 @@ func TestPriorityAfterUpsert(t *testing.T) {
 		"n": 19,
 	})
 }
+
+type cloneItem struct {
+	key string
+	val []byte
+}
+
+func (c *cloneItem) Clone() Item {
+	return &cloneItem{key: c.key, val: append([]byte(nil), c.val...)}
+}
+
+// countedClone is a cloneItem that counts its clones.
+type countedClone struct {
+	cloneItem
+	n *int
+}
+
+func (c *countedClone) Clone() Item {
+	*c.n++
+	return &countedClone{c.cloneItem, c.n}
+}
+
+func cloneItemCompare(a, b interface{}) int {
+	return stringCompare(a.(*cloneItem).key, b.(*cloneItem).key)
+}
+
+func TestCloneItems(t *testing.T) {
+	x := NewTreapWithOptions(cloneItemCompare, Options{CloneItems: true})
+
+	in := &cloneItem{key: "a", val: []byte("1")}
+	x = x.Upsert(in, 1)
+	x = x.Upsert(&cloneItem{key: "b", val: []byte("2")}, 2)
+
+	// Mutating the caller's item after an Upsert should not matter.
+	in.key = "z"
+	in.val[0] = 'x'
+
+	got := x.Get(&cloneItem{key: "a"}).(*cloneItem)
+	if got == in || string(got.val) != "1" {
+		t.Errorf("expected a copy of the originally upserted item")
+	}
+
+	// Mutating a returned item should not affect the treap either.
+	got.val[0] = 'y'
+	if string(x.Get(&cloneItem{key: "a"}).(*cloneItem).val) != "1" {
+		t.Errorf("expected Get to return independent copies")
+	}
+	x.Min().(*cloneItem).key = "zz"
+	x.Max().(*cloneItem).key = "zz"
+	x.VisitAscend(&cloneItem{key: "a"}, func(i Item) bool {
+		i.(*cloneItem).key = "zz"
+		return true
+	})
+	visited := []string{}
+	x.VisitAscend(&cloneItem{key: "a"}, func(i Item) bool {
+		visited = append(visited, i.(*cloneItem).key)
+		return true
+	})
+	if len(visited) != 2 || visited[0] != "a" || visited[1] != "b" {
+		t.Errorf("expected visit of a, b, got: %v", visited)
+	}
+
+	// Without the option, items are stored and returned as is.
+	y := NewTreap(cloneItemCompare)
+	y = y.Upsert(in, 1)
+	if y.Get(&cloneItem{key: "z"}) != in {
+		t.Errorf("expected the same item without CloneItems")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{
+		MaxItemSize:  4,
+		MaxTotalSize: 6,
+	})
+
+	x, err := x.TryUpsert("aaaaa", 1)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded for a too large item, got: %v", err)
+	}
+	if x.Get("aaaaa") != nil || x.ItemsSize() != 0 {
+		t.Errorf("expected failed TryUpsert to leave the treap unchanged")
+	}
+
+	x, err = x.TryUpsert("aaa", 1)
+	if err != nil {
+		t.Errorf("expected TryUpsert to work, got: %v", err)
+	}
+	x, err = x.TryUpsert("bbb", 2)
+	if err != nil {
+		t.Errorf("expected TryUpsert to work, got: %v", err)
+	}
+	if x.ItemsSize() != 6 {
+		t.Errorf("expected items size 6, got: %v", x.ItemsSize())
+	}
+
+	// Replacing an existing item does not double count it.
+	x, err = x.TryUpsert("aaa", 3)
+	if err != nil {
+		t.Errorf("expected TryUpsert of existing item to work, got: %v", err)
+	}
+
+	y, err := x.TryUpsert("c", 4)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded for total size, got: %v", err)
+	}
+	if y != x {
+		t.Errorf("expected failed TryUpsert to return the same treap")
+	}
+
+	x = x.Delete("bbb")
+	if x.ItemsSize() != 3 {
+		t.Errorf("expected items size 3 after delete, got: %v", x.ItemsSize())
+	}
+	x = x.Delete("not-there")
+	if x.ItemsSize() != 3 {
+		t.Errorf("expected items size 3 after no-op delete, got: %v", x.ItemsSize())
+	}
+	x, err = x.TryUpsert("c", 4)
+	if err != nil || x.ItemsSize() != 4 {
+		t.Errorf("expected TryUpsert after delete to work, got: %v, %v",
+			err, x.ItemsSize())
+	}
+
+	// Plain Upsert keeps tracking even though it does not enforce.
+	x = x.Upsert("dddd", 5)
+	if x.ItemsSize() != 8 {
+		t.Errorf("expected items size 8, got: %v", x.ItemsSize())
+	}
+
+	// Without limits, TryUpsert always works.
+	z, err := NewTreap(stringCompare).TryUpsert("aaaaa", 1)
+	if err != nil || z.Get("aaaaa") != "aaaaa" || z.ItemsSize() != 5 {
+		t.Errorf("expected unlimited TryUpsert to work")
+	}
+}
+
+func TestRangeAndItems(t *testing.T) {
+	x := NewTreap(stringCompare)
+	if x.Items() != nil {
+		t.Errorf("expected no items")
+	}
+	x.Range(func(i Item) bool {
+		t.Errorf("expected no Range callbacks")
+		return true
+	})
+
+	x = load(x, []string{"c", "a", "b", "d"})
+	if !reflect.DeepEqual(x.Items(), []Item{"a", "b", "c", "d"}) {
+		t.Errorf("unexpected items: %v", x.Items())
+	}
+
+	var seen []Item
+	x.Range(func(i Item) bool {
+		seen = append(seen, i)
+		return i != "b"
+	})
+	if !reflect.DeepEqual(seen, []Item{"a", "b"}) {
+		t.Errorf("expected Range to stop early, saw: %v", seen)
+	}
+}
+
+func benchmarkTreap(n int) (*Treap, []Item) {
+	x := NewTreap(testutil.IntCompare)
+	entries := testutil.Generate(testutil.Config{
+		Seed: 0, N: n, Keys: testutil.Uniform, KeyRange: n,
+	})
+	keys := make([]Item, n)
+	for i, e := range entries {
+		keys[i] = e.Key
+		x = x.Upsert(e.Key, e.Priority)
+	}
+	return x, keys
+}
+
+func intCompare(a, b interface{}) int {
+	return a.(int) - b.(int)
+}
+
+func BenchmarkGet(b *testing.B) {
+	x, keys := benchmarkTreap(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Get(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkMin(b *testing.B) {
+	x, _ := benchmarkTreap(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Min()
+	}
+}
+
+func BenchmarkUpsert(b *testing.B) {
+	x, keys := benchmarkTreap(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Upsert(keys[i%len(keys)], i)
+	}
+}
+
+func BenchmarkVisitAscend(b *testing.B) {
+	x, _ := benchmarkTreap(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		x.VisitAscend(50000, func(i Item) bool {
+			n++
+			return n < 100
+		})
+	}
+}
+
+func TestNodeSize(t *testing.T) {
+	// A node should stay within a single 64 byte cache line.
+	if s := unsafe.Sizeof(node{}); s > 64 {
+		t.Errorf("expected node size <= 64 bytes, got: %v", s)
+	}
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100})
+	x = load(x, []string{"t05", "t01", "t03", "t02", "t04", "t06"})
+
+	tests := []struct {
+		pivot string
+		exp   []Item
+		stats RetentionStats
+	}{
+		{"t00", []Item{"t01", "t02", "t03", "t04", "t05", "t06"}, RetentionStats{}},
+		{"t03", []Item{"t03", "t04", "t05", "t06"}, RetentionStats{2, 6}},
+		{"t035", []Item{"t04", "t05", "t06"}, RetentionStats{3, 9}},
+		{"t99", nil, RetentionStats{6, 18}},
+	}
+	for testIdx, test := range tests {
+		y, stats := x.DeleteOlderThan(test.pivot)
+		if !reflect.DeepEqual(y.Items(), test.exp) {
+			t.Errorf("test: %v, expected: %v, got: %v", testIdx, test.exp, y.Items())
+		}
+		if stats != test.stats {
+			t.Errorf("test: %v, expected stats: %v, got: %v", testIdx, test.stats, stats)
+		}
+		if y.ItemsSize() != x.ItemsSize()-stats.Bytes {
+			t.Errorf("test: %v, expected items size to shrink, got: %v",
+				testIdx, y.ItemsSize())
+		}
+	}
+	if len(x.Items()) != 6 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+
+	// Removed items are counted from the subtree, not visited, so
+	// they are not cloned.
+	clones := 0
+	y := NewTreapWithOptions(func(a, b interface{}) int {
+		return stringCompare(a.(*countedClone).key, b.(*countedClone).key)
+	}, Options{CloneItems: true})
+	for i, k := range []string{"a", "b", "c", "d"} {
+		y = y.Upsert(&countedClone{cloneItem{key: k}, &clones}, i)
+	}
+	clones = 0
+	if _, stats := y.DeleteOlderThan(&countedClone{cloneItem{key: "c"}, &clones}); stats.Items != 2 || clones != 0 {
+		t.Errorf("expected 2 items released without clones, got: %v, %v",
+			stats.Items, clones)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	errEmpty := errors.New("empty key")
+	x := NewTreapWithOptions(stringCompare, Options{
+		Normalize: func(i Item) (Item, error) {
+			s := strings.ToLower(strings.TrimSpace(i.(string)))
+			if s == "" {
+				return nil, errEmpty
+			}
+			return s, nil
+		},
+	})
+
+	x = x.Upsert("  Hello", 1)
+	x = x.Upsert("HELLO ", 2)
+	x, err := x.TryUpsert("World", 3)
+	if err != nil {
+		t.Errorf("expected TryUpsert to work, got: %v", err)
+	}
+	if !reflect.DeepEqual(x.Items(), []Item{"hello", "world"}) {
+		t.Errorf("expected normalized items, got: %v", x.Items())
+	}
+
+	y, err := x.TryUpsert("   ", 4)
+	if err != errEmpty || y != x {
+		t.Errorf("expected the Normalize error and no change, got: %v", err)
+	}
+	if x.Upsert("", 5) != x {
+		t.Errorf("expected Upsert to ignore items failing Normalize")
+	}
+
+	// Lookups are not normalized.
+	if x.Get("Hello") != nil || x.Get("hello") != "hello" {
+		t.Errorf("expected Get targets to be used as is")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	hash := func(i Item) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(i.(string)))
+		return h.Sum64()
+	}
+	x := NewTreapWithOptions(stringCompare, Options{Hash: hash})
+	if x.Fingerprint() != 0 {
+		t.Errorf("expected an empty treap to have a 0 fingerprint")
+	}
+
+	x = load(x, []string{"a", "b", "c", "d", "e"})
+	y := NewTreapWithOptions(stringCompare, Options{Hash: hash})
+	y = y.Upsert("e", 50)
+	y = y.Upsert("c", 1)
+	y = y.Upsert("a", 7)
+	y = y.Upsert("d", 100)
+	y = y.Upsert("b", 3)
+	if x.Fingerprint() == 0 || x.Fingerprint() != y.Fingerprint() {
+		t.Errorf("expected equal contents to have equal fingerprints")
+	}
+
+	z := x.Delete("c")
+	if z.Fingerprint() == x.Fingerprint() {
+		t.Errorf("expected a delete to change the fingerprint")
+	}
+	if z.Upsert("c", 1000).Fingerprint() != x.Fingerprint() {
+		t.Errorf("expected re-adding the item to restore the fingerprint")
+	}
+	if x.Upsert("c", 1000).Fingerprint() != x.Fingerprint() {
+		t.Errorf("expected replacing an item with itself to keep the fingerprint")
+	}
+	if x.Upsert("f", 1).Fingerprint() == x.Fingerprint() {
+		t.Errorf("expected an upsert to change the fingerprint")
+	}
+	w, _ := x.DeleteOlderThan("c")
+	if w.Fingerprint() != x.Delete("a").Delete("b").Fingerprint() {
+		t.Errorf("expected DeleteOlderThan to maintain the fingerprint")
+	}
+
+	if NewTreap(stringCompare).Upsert("a", 1).Fingerprint() != 0 {
+		t.Errorf("expected a 0 fingerprint without Options.Hash")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	// Priorities increasing with the items degenerate the treap into
+	// a left leaning linked list.
+	x := NewTreapWithOptions(intCompare, Options{MaxDepth: 9})
+	var err error
+	for i := 0; i < 10; i++ {
+		x, err = x.TryUpsert(i, i)
+		if err != nil {
+			t.Fatalf("expected TryUpsert to work at %v, got: %v", i, err)
+		}
+	}
+
+	// The search path for -1 goes through all 10 nodes.
+	y, err := x.TryUpsert(-1, -1)
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxDepth" || le.Size != 10 {
+		t.Errorf("expected a MaxDepth LimitError, got: %v", err)
+	}
+	if !errors.Is(err, ErrLimitExceeded) || y != x {
+		t.Errorf("expected ErrLimitExceeded and an unchanged treap")
+	}
+
+	// High priority items go near the root.
+	if _, err = x.TryUpsert(100, 100); err != nil {
+		t.Errorf("expected a shallow TryUpsert to work, got: %v", err)
+	}
+
+	if _, err = x.TryDelete(0); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected a deep TryDelete to fail, got: %v", err)
+	}
+	z, err := x.TryDelete(9)
+	if err != nil || z.Size() != 9 {
+		t.Errorf("expected a shallow TryDelete to work, got: %v", err)
+	}
+
+	// Deleting a node joins the spines below it, here 3-4 and 7-6.
+	w := NewTreapWithOptions(intCompare, Options{MaxDepth: 4})
+	w = w.Upsert(5, 10).Upsert(3, 9).Upsert(7, 9).Upsert(4, 8).Upsert(6, 8)
+	if _, err = w.TryDelete(5); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected TryDelete to count the joined spines, got: %v", err)
+	}
+	if _, err = w.TryDelete(4); err != nil {
+		t.Errorf("expected TryDelete of a leaf to work, got: %v", err)
+	}
+
+	if _, err = NewTreap(intCompare).Upsert(1, 1).TryDelete(1); err != nil {
+		t.Errorf("expected no depth checks without MaxDepth, got: %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100})
+	x = load(x, []string{"d", "b", "f", "a", "c", "e", "g"})
+
+	tests := []struct {
+		item         string
+		left, right  []Item
+		found        Item
+		leftItemSize int
+	}{
+		{"d", []Item{"a", "b", "c"}, []Item{"e", "f", "g"}, "d", 3},
+		{"cc", []Item{"a", "b", "c"}, []Item{"d", "e", "f", "g"}, nil, 3},
+		{"a", nil, []Item{"b", "c", "d", "e", "f", "g"}, "a", 0},
+		{"z", []Item{"a", "b", "c", "d", "e", "f", "g"}, nil, nil, 7},
+	}
+	for testIdx, test := range tests {
+		left, found, right := x.Split(test.item)
+		if !reflect.DeepEqual(left.Items(), test.left) ||
+			!reflect.DeepEqual(right.Items(), test.right) || found != test.found {
+			t.Errorf("test: %v, expected: %v, %v, %v, got: %v, %v, %v", testIdx,
+				test.left, test.found, test.right, left.Items(), found, right.Items())
+		}
+		checkSizes(t, left.root)
+		checkSizes(t, right.root)
+		rightItemSize := 7 - test.leftItemSize
+		if found != nil {
+			rightItemSize--
+		}
+		if left.ItemsSize() != test.leftItemSize || right.ItemsSize() != rightItemSize {
+			t.Errorf("test: %v, expected items sizes: %v, %v, got: %v, %v", testIdx,
+				test.leftItemSize, rightItemSize, left.ItemsSize(), right.ItemsSize())
+		}
+	}
+	if x.Size() != 7 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100})
+	x = load(x, []string{"d", "b", "f", "a", "c", "e", "g"})
+	left, found, right := x.Split("d")
+	y := left.Join(right)
+	if !reflect.DeepEqual(y.Items(), []Item{"a", "b", "c", "e", "f", "g"}) {
+		t.Errorf("expected the items without d, got: %v", y.Items())
+	}
+	checkSizes(t, y.root)
+	checkHeap(t, y.root)
+	if y.ItemsSize() != x.ItemsSize()-1 {
+		t.Errorf("expected items size: %v, got: %v", x.ItemsSize()-1, y.ItemsSize())
+	}
+	z := left.Join(NewTreap(stringCompare).Upsert(found, 0)).Join(right)
+	if !reflect.DeepEqual(z.Items(), x.Items()) {
+		t.Errorf("expected the original items, got: %v", z.Items())
+	}
+	if e := NewTreap(stringCompare); e.Join(left).Size() != 3 || left.Join(e).Size() != 3 {
+		t.Errorf("expected a join with an empty treap to keep the items")
+	}
+}
+
+// nilCalls invokes every method that takes an item with nil, and is
+// also used with a nil *Treap.
+func nilCalls(x *Treap) map[string]func() bool {
+	v := func(i Item) bool { return true }
+	return map[string]func() bool{
+		"Upsert":     func() bool { return x.Upsert(nil, 1) == x },
+		"UpsertEx":   func() bool { y, _, ok := x.UpsertEx(nil, 1); return y == x && !ok },
+		"UpsertWith": func() bool { return x.UpsertWith(nil, 1, nil) == x },
+		"UpsertWith merge": func() bool {
+			return x.UpsertWith("a", 1, func(existing, item Item) Item { return nil }) == x
+		},
+		"UnionWith resolve": func() bool {
+			y := x.UnionWith(x, func(a, b Item) Item { return nil })
+			return y.Size() == x.Size() && y.Get("a") == x.Get("a") &&
+				!reflect.DeepEqual(y.Items(), []Item{nil})
+		},
+		"InsertIfAbsent":  func() bool { y, ok := x.InsertIfAbsent(nil, 1); return y == x && !ok },
+		"UpdatePriority":  func() bool { return x.UpdatePriority(nil, 1) == x },
+		"Delete":          func() bool { return x.Delete(nil) == x },
+		"DeleteEx":        func() bool { i, ok, y := x.DeleteEx(nil); return i == nil && !ok && y == x },
+		"TryDelete":       func() bool { y, err := x.TryDelete(nil); return y == x && err == nil },
+		"DeleteOlderThan": func() bool { y, _ := x.DeleteOlderThan(nil); return y == x },
+		"Get":             func() bool { return x.Get(nil) == nil },
+		"GetPriority":     func() bool { _, ok := x.GetPriority(nil); return !ok },
+		"Rank":            func() bool { return x.Rank(nil) == 0 },
+		"Floor":           func() bool { return x.Floor(nil) == nil },
+		"Ceiling":         func() bool { return x.Ceiling(nil) == nil },
+		"Neighbors":       func() bool { p, s := x.Neighbors(nil); return p == nil && s == nil },
+		"GetMany":         func() bool { return x.GetMany([]Item{nil})[0] == nil },
+		"ContainsMany":    func() bool { return !x.ContainsMany([]Item{nil, nil})[1] },
+		"Seek":            func() bool { return !x.Cursor().Seek(nil) },
+		"Buckets":         func() bool { return x.Buckets([]Item{nil})[0] == 0 },
+		"Downsample":      func() bool { x.Downsample([]Item{nil, nil}, countReducer); return true },
+		"Explain":         func() bool { x.Explain(Query{}); return true },
+		"Run":             func() bool { x.Run(Query{}, v); return true },
+		"VisitAscend":     func() bool { x.VisitAscend(nil, v); return true },
+		"VisitDescend":    func() bool { x.VisitDescend(nil, v); return true },
+		"VisitRange":      func() bool { x.VisitRange(nil, nil, v); return true },
+		"ParallelVisit":   func() bool { x.ParallelVisit(nil, 2, v); return true },
+		"FindGaps": func() bool {
+			x.FindGaps(nil, nil, func(i Item) Item { return i },
+				func(from, to Item) bool { return true })
+			return true
+		},
+	}
+}
+
+func TestNilItems(t *testing.T) {
+	x := load(NewTreap(stringCompare), []string{"b", "a", "c"})
+	for name, call := range nilCalls(x) {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%v: expected no panic on a nil item, got: %v", name, r)
+				}
+			}()
+			if !call() {
+				t.Errorf("%v: expected nil to be neither stored nor found", name)
+			}
+		}()
+	}
+	if x.Size() != 3 {
+		t.Errorf("expected the treap to be unchanged")
+	}
+
+	if _, err := x.TryUpsert(nil, 1); err != ErrNilItem {
+		t.Errorf("expected ErrNilItem, got: %v", err)
+	}
+	y := NewTreapWithOptions(stringCompare, Options{
+		Normalize: func(i Item) (Item, error) { return nil, nil },
+	})
+	if _, err := y.TryUpsert("a", 1); err != ErrNilItem {
+		t.Errorf("expected ErrNilItem from a nil Normalize result, got: %v", err)
+	}
+	if err := NewBuilder(stringCompare, Options{}).Load(nil); err != ErrNilItem {
+		t.Errorf("expected ErrNilItem from Load, got: %v", err)
+	}
+	if y := SortInto([]Item{"b", nil, "a", nil}, stringCompare); !reflect.DeepEqual(
+		y.Items(), []Item{"a", "b"}) {
+		t.Errorf("expected SortInto to skip nil items, got: %v", y.Items())
+	}
+	var m sync.Map
+	m.Store(1, "a")
+	m.Store(2, nil)
+	if y := FromSyncMap(&m, stringCompare); !reflect.DeepEqual(y.Items(), []Item{"a"}) {
+		t.Errorf("expected FromSyncMap to skip nil values, got: %v", y.Items())
+	}
+
+	var got []Item
+	x.VisitAscend(nil, func(i Item) bool { got = append(got, i); return true })
+	x.VisitDescend(nil, func(i Item) bool { got = append(got, i); return true })
+	x.VisitRange("b", nil, func(i Item) bool { got = append(got, i); return true })
+	if !reflect.DeepEqual(got, []Item{"a", "b", "c", "c", "b", "a", "b", "c"}) {
+		t.Errorf("expected nil pivots to be unbounded, got: %v", got)
+	}
+	if l, found, r := x.Split(nil); l.Size() != 0 || found != nil || r != x {
+		t.Errorf("expected Split at nil to put every item on the right")
+	}
+}
+
+func TestNilTreap(t *testing.T) {
+	var x *Treap
+	calls := nilCalls(x)
+	calls["Size"] = func() bool { return x.Size() == 0 && x.ItemsSize() == 0 }
+	calls["Min"] = func() bool { return x.Min() == nil && x.Max() == nil }
+	calls["Select"] = func() bool { return x.Select(0) == nil }
+	calls["Fingerprint"] = func() bool { return x.Fingerprint() == 0 }
+	calls["Items"] = func() bool { return len(x.Items()) == 0 }
+	calls["Upsert item"] = func() bool { return x.Upsert("a", 1) == nil }
+	calls["Delete item"] = func() bool { return x.Delete("a") == nil }
+	calls["DeleteMin"] = func() bool { i, y := x.DeleteMin(); return i == nil && y == nil }
+	calls["DeleteMax"] = func() bool { i, y := x.DeleteMax(); return i == nil && y == nil }
+	calls["Split"] = func() bool { l, _, r := x.Split("a"); return l == nil && r == nil }
+	calls["Join"] = func() bool { return x.Join(nil) == nil }
+	calls["UnionWith"] = func() bool { return x.UnionWith(nil, nil) == nil }
+	calls["IntersectWith"] = func() bool { return x.IntersectWith(nil) == nil }
+	calls["DedupBy"] = func() bool { return x.DedupBy(PrefixGroup(1)) == nil }
+	calls["SplitIntoN"] = func() bool { return len(x.SplitIntoN(2)) == 0 }
+	calls["GroupReduce"] = func() bool { x.GroupReduce(PrefixGroup(1), countReducer, nil); return true }
+	calls["Cursor"] = func() bool { c := x.Cursor(); return !c.First() && !c.Last() && !c.Next() }
+	calls["CopyTo"] = func() bool { return x.CopyTo(NewBuilder(stringCompare, Options{})) == nil }
+	calls["ItemsChan"] = func() bool {
+		_, ok := <-x.ItemsChan(context.Background(), nil)
+		return !ok
+	}
+	for name, call := range calls {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%v: expected no panic on a nil treap, got: %v", name, r)
+				}
+			}()
+			if !call() {
+				t.Errorf("%v: expected a nil treap to act as empty", name)
+			}
+		}()
+	}
+
+	if y, err := x.TryUpsert("a", 1); y != nil || err != ErrNilTreap {
+		t.Errorf("expected ErrNilTreap, got: %v", err)
+	}
+	y := load(NewTreap(stringCompare), []string{"a"})
+	if y.Join(x).Size() != 1 || x.Join(y) != y || x.UnionWith(y, nil) != y {
+		t.Errorf("expected a nil treap to act as empty")
+	}
+	if y.UnionWith(x, nil).Size() != 1 || y.IntersectWith(x).Size() != 0 {
+		t.Errorf("expected a nil treap to act as empty")
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100})
+	x = load(x, []string{"d", "b", "f", "a", "c", "e", "g"})
+
+	tests := []struct {
+		low, high Item
+		exp       []Item
+	}{
+		{"b", "e", []Item{"a", "e", "f", "g"}},
+		{"bb", "ee", []Item{"a", "b", "f", "g"}},
+		{"a", "b", []Item{"b", "c", "d", "e", "f", "g"}},
+		{"c", "c", []Item{"a", "b", "c", "d", "e", "f", "g"}},
+		{"e", "c", []Item{"a", "b", "c", "d", "e", "f", "g"}},
+		{nil, "c", []Item{"c", "d", "e", "f", "g"}},
+		{"f", nil, []Item{"a", "b", "c", "d", "e"}},
+		{nil, nil, nil},
+	}
+	for testIdx, test := range tests {
+		y := x.DeleteRange(test.low, test.high)
+		if !reflect.DeepEqual(y.Items(), test.exp) {
+			t.Errorf("test: %v, expected: %v, got: %v", testIdx, test.exp, y.Items())
+		}
+		checkSizes(t, y.root)
+		checkHeap(t, y.root)
+		if y.ItemsSize() != len(test.exp) {
+			t.Errorf("test: %v, expected items size: %v, got: %v",
+				testIdx, len(test.exp), y.ItemsSize())
+		}
+	}
+	if x.Size() != 7 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+}
+
+func TestDeleteLessAndGreaterThan(t *testing.T) {
+	x := NewTreapWithOptions(stringCompare, Options{MaxTotalSize: 100})
+	x = load(x, []string{"t05", "t01", "t03", "t02", "t04"})
+
+	tests := []struct {
+		pivot                   Item
+		less, lessRemoved       []Item
+		greater, greaterRemoved []Item
+	}{
+		{"t03", []Item{"t03", "t04", "t05"}, []Item{"t01", "t02"},
+			[]Item{"t01", "t02", "t03"}, []Item{"t04", "t05"}},
+		{"t035", []Item{"t04", "t05"}, []Item{"t01", "t02", "t03"},
+			[]Item{"t01", "t02", "t03"}, []Item{"t04", "t05"}},
+		{"t00", x.Items(), nil, nil, x.Items()},
+		{"t99", nil, x.Items(), x.Items(), nil},
+		{nil, x.Items(), nil, x.Items(), nil},
+	}
+	for testIdx, test := range tests {
+		for _, c := range []struct {
+			f             func(Item) (*Treap, *Treap)
+			kept, removed []Item
+		}{
+			{x.DeleteLessThan, test.less, test.lessRemoved},
+			{x.DeleteGreaterThan, test.greater, test.greaterRemoved},
+		} {
+			y, removed := c.f(test.pivot)
+			if !reflect.DeepEqual(y.Items(), c.kept) ||
+				!reflect.DeepEqual(removed.Items(), c.removed) {
+				t.Errorf("test: %v, expected: %v, %v, got: %v, %v", testIdx,
+					c.kept, c.removed, y.Items(), removed.Items())
+			}
+			checkSizes(t, y.root)
+			checkHeap(t, y.root)
+			if y.ItemsSize() != 3*len(c.kept) || removed.ItemsSize() != 3*len(c.removed) {
+				t.Errorf("test: %v, expected items sizes to be split, got: %v, %v",
+					testIdx, y.ItemsSize(), removed.ItemsSize())
+			}
+		}
+	}
+	if x.Size() != 5 {
+		t.Errorf("expected the original treap to be unchanged")
+	}
+}
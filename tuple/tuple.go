@@ -0,0 +1,236 @@
+// Package tuple implements an order-preserving encoding of tuples of
+// simple values into []byte keys, in the spirit of FoundationDB's
+// tuple layer.  Encoded keys compare with bytes.Compare in the same
+// order as their tuples compare field by field, so multi-field keys
+// can be stored as []byte items in a gtreap.Treap using Compare.
+//
+// Supported element types are nil, []byte, string, bool, the signed
+// integer types (decoded as int64) and time.Time (decoded as UTC with
+// nanosecond precision).  Elements of different types order by type:
+// nil < []byte < string < integers < bools < times.
+//
+// Wrapping an element in Desc makes that field order descending,
+// including the order of types, so keys like (tenant ASC, created_at
+// DESC) need no custom comparator.
+package tuple
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrInvalid is returned when decoding malformed input.
+var ErrInvalid = errors.New("tuple: invalid encoding")
+
+// Type codes start at 0x01, so that no bit flipped code is 0xff,
+// which would read as the 0x00 0xff escape when following a 0x00
+// escaped value terminator.
+const (
+	codeNil   = 0x01
+	codeBytes = 0x02
+	codeStr   = 0x03
+	codeInt   = 0x16
+	codeFalse = 0x27
+	codeTrue  = 0x28
+	codeTime  = 0x34
+)
+
+// Desc wraps an element so that it orders in descending rather than
+// ascending order, by encoding it with all its bits flipped.  Decode
+// returns descending elements wrapped in a Desc.
+type Desc struct {
+	V interface{}
+}
+
+// Compare is a gtreap.Compare for []byte items produced by Encode.
+func Compare(a, b interface{}) int {
+	return bytes.Compare(a.([]byte), b.([]byte))
+}
+
+// Encode returns the order-preserving encoding of the elements.
+func Encode(elems ...interface{}) ([]byte, error) {
+	var buf []byte
+	for i, e := range elems {
+		var err error
+		buf, err = appendElem(buf, e, false)
+		if err != nil {
+			return nil, fmt.Errorf("tuple: element %d: %w", i, err)
+		}
+	}
+	return buf, nil
+}
+
+func appendElem(buf []byte, e interface{}, desc bool) ([]byte, error) {
+	switch v := e.(type) {
+	case Desc:
+		if desc {
+			return nil, errors.New("nested Desc")
+		}
+		start := len(buf)
+		buf, err := appendElem(buf, v.V, true)
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i < len(buf); i++ {
+			buf[i] ^= 0xff
+		}
+		return buf, nil
+	case nil:
+		return append(buf, codeNil), nil
+	case []byte:
+		return appendEscaped(append(buf, codeBytes), v, desc), nil
+	case string:
+		return appendEscaped(append(buf, codeStr), []byte(v), desc), nil
+	case int:
+		return appendInt(buf, int64(v)), nil
+	case int8:
+		return appendInt(buf, int64(v)), nil
+	case int16:
+		return appendInt(buf, int64(v)), nil
+	case int32:
+		return appendInt(buf, int64(v)), nil
+	case int64:
+		return appendInt(buf, v), nil
+	case bool:
+		if v {
+			return append(buf, codeTrue), nil
+		}
+		return append(buf, codeFalse), nil
+	case time.Time:
+		if v.Before(minTime) || v.After(maxTime) {
+			return nil, errors.New("time out of range")
+		}
+		return appendUint64(append(buf, codeTime), uint64(v.UnixNano())^signBit), nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", e)
+}
+
+var (
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+const signBit = 1 << 63
+
+// appendEscaped writes b terminated by 0x00, with each 0x00 inside b
+// escaped as 0x00 0xff, so shorter values order before longer ones.
+// A value that is going to be bit flipped for descending order is
+// terminated by 0x00 0x00 instead, so that its encoding is never a
+// prefix of a longer value's and flipping reverses the order exactly.
+func appendEscaped(buf []byte, b []byte, desc bool) []byte {
+	for _, c := range b {
+		buf = append(buf, c)
+		if c == 0x00 {
+			buf = append(buf, 0xff)
+		}
+	}
+	if desc {
+		buf = append(buf, 0x00)
+	}
+	return append(buf, 0x00)
+}
+
+// appendInt writes a fixed width big-endian value with the sign bit
+// flipped, so negative numbers order before positive ones.
+func appendInt(buf []byte, v int64) []byte {
+	return appendUint64(append(buf, codeInt), uint64(v)^signBit)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// Decode returns the elements of an encoded tuple.
+func Decode(b []byte) ([]interface{}, error) {
+	var res []interface{}
+	for len(b) > 0 {
+		e, rest, err := decodeElem(b)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+		b = rest
+	}
+	return res, nil
+}
+
+// decodeElem decodes the element at the start of b.  All type codes
+// are < 0x80, so a larger first byte means a bit flipped, descending
+// element.
+func decodeElem(b []byte) (interface{}, []byte, error) {
+	if b[0] >= 0x80 {
+		v, rest, err := decodeValue(b, 0xff)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Desc{V: v}, rest, nil
+	}
+	return decodeValue(b, 0x00)
+}
+
+// decodeValue decodes an element whose bytes are xor'ed with flip.
+func decodeValue(b []byte, flip byte) (interface{}, []byte, error) {
+	switch b[0] ^ flip {
+	case codeNil:
+		return nil, b[1:], nil
+	case codeBytes:
+		v, rest, err := decodeEscaped(b[1:], flip)
+		return v, rest, err
+	case codeStr:
+		v, rest, err := decodeEscaped(b[1:], flip)
+		return string(v), rest, err
+	case codeInt:
+		v, rest, err := decodeUint64(b[1:], flip)
+		return int64(v ^ signBit), rest, err
+	case codeFalse:
+		return false, b[1:], nil
+	case codeTrue:
+		return true, b[1:], nil
+	case codeTime:
+		v, rest, err := decodeUint64(b[1:], flip)
+		return time.Unix(0, int64(v^signBit)).UTC(), rest, err
+	}
+	return nil, nil, fmt.Errorf("%w: unknown type code 0x%02x", ErrInvalid, b[0])
+}
+
+func decodeEscaped(b []byte, flip byte) ([]byte, []byte, error) {
+	res := []byte{}
+	for i := 0; i < len(b); i++ {
+		c := b[i] ^ flip
+		if c != 0x00 {
+			res = append(res, c)
+			continue
+		}
+		if i+1 < len(b) && b[i+1]^flip == 0xff {
+			res = append(res, 0x00)
+			i++
+			continue
+		}
+		if flip != 0x00 {
+			// Descending values have a 0x00 0x00 terminator.
+			if i+1 >= len(b) || b[i+1]^flip != 0x00 {
+				break
+			}
+			i++
+		}
+		return res, b[i+1:], nil
+	}
+	return nil, nil, fmt.Errorf("%w: unterminated value", ErrInvalid)
+}
+
+func decodeUint64(b []byte, flip byte) (uint64, []byte, error) {
+	if len(b) < 8 {
+		return 0, nil, fmt.Errorf("%w: short value", ErrInvalid)
+	}
+	var v [8]byte
+	for i := range v {
+		v[i] = b[i] ^ flip
+	}
+	return binary.BigEndian.Uint64(v[:]), b[8:], nil
+}
@@ -0,0 +1,208 @@
+package tuple
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/steveyen/gtreap"
+)
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 123).UTC()
+	tests := [][]interface{}{
+		nil,
+		{nil},
+		{[]byte{}, []byte("a\x00b"), []byte{0xff}},
+		{"", "hello", "nul\x00inside"},
+		{int64(0), int64(-1), int64(math.MinInt64), int64(math.MaxInt64)},
+		{true, false},
+		{now},
+		{"tenant", int64(42), true, nil, now, []byte("x")},
+	}
+	for testIdx, test := range tests {
+		b, err := Encode(test...)
+		if err != nil {
+			t.Fatalf("test: %v, Encode err: %v", testIdx, err)
+		}
+		got, err := Decode(b)
+		if err != nil {
+			t.Fatalf("test: %v, Decode err: %v", testIdx, err)
+		}
+		if !reflect.DeepEqual(got, test) {
+			t.Errorf("test: %v, expected: %#v, got: %#v", testIdx, test, got)
+		}
+	}
+
+	b, _ := Encode(int(7), int8(-8), int16(9), int32(-10))
+	got, _ := Decode(b)
+	if !reflect.DeepEqual(got, []interface{}{int64(7), int64(-8), int64(9), int64(-10)}) {
+		t.Errorf("expected integers to decode as int64, got: %#v", got)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	t0 := time.Unix(0, 0)
+	// Tuples listed in their expected order.
+	ordered := [][]interface{}{
+		{},
+		{nil},
+		{[]byte{}},
+		{[]byte{0x00}},
+		{[]byte{0x00, 0x00}},
+		{[]byte{0x01}},
+		{""},
+		{"a"},
+		{"a", nil},
+		{"a", "b"},
+		{"a\x00"},
+		{"a\x00b"},
+		{"ab"},
+		{"b"},
+		{int64(math.MinInt64)},
+		{-1000},
+		{-1},
+		{0},
+		{0, "x"},
+		{1},
+		{255},
+		{256},
+		{int64(math.MaxInt64)},
+		{false},
+		{true},
+		{t0.Add(-time.Second)},
+		{t0},
+		{t0.Add(time.Nanosecond)},
+	}
+	var keys [][]byte
+	for _, tup := range ordered {
+		b, err := Encode(tup...)
+		if err != nil {
+			t.Fatalf("Encode err: %v", err)
+		}
+		keys = append(keys, b)
+	}
+	for i := 1; i < len(keys); i++ {
+		if Compare(keys[i-1], keys[i]) >= 0 {
+			t.Errorf("expected %v < %v", ordered[i-1], ordered[i])
+		}
+	}
+
+	// The keys should work as items of a treap.
+	x := gtreap.NewTreap(Compare)
+	for i, k := range rand.New(rand.NewSource(0)).Perm(len(keys)) {
+		x = x.Upsert(keys[k], i)
+	}
+	n := 0
+	x.Range(func(i gtreap.Item) bool {
+		if Compare(i, keys[n]) != 0 {
+			t.Errorf("expected treap order to match tuple order at %v", n)
+		}
+		n++
+		return true
+	})
+	if n != len(keys) {
+		t.Errorf("expected %v items, got: %v", len(keys), n)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Encode(1.5); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+	if _, err := Encode(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Errorf("expected an error for an out of range time")
+	}
+	for _, b := range [][]byte{
+		{0x77},
+		{codeStr, 'a'},
+		{codeInt, 0x01, 0x02},
+		{codeTime},
+	} {
+		if _, err := Decode(b); !errors.Is(err, ErrInvalid) {
+			t.Errorf("expected ErrInvalid decoding %v, got: %v", b, err)
+		}
+	}
+}
+
+func TestDesc(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	tup := []interface{}{
+		"tenant", Desc{int64(-5)}, Desc{"a\x00b"}, Desc{nil},
+		Desc{[]byte("xy")}, Desc{true}, Desc{now}, int64(1),
+	}
+	b, err := Encode(tup...)
+	if err != nil {
+		t.Fatalf("Encode err: %v", err)
+	}
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatalf("Decode err: %v", err)
+	}
+	if !reflect.DeepEqual(got, tup) {
+		t.Errorf("expected: %#v, got: %#v", tup, got)
+	}
+
+	// (tenant ASC, field DESC), listed in their expected order.  The
+	// order of types is reversed for descending fields too.
+	ordered := [][]interface{}{
+		{"t1", Desc{true}},
+		{"t1", Desc{false}},
+		{"t1", Desc{100}},
+		{"t1", Desc{-100}},
+		{"t1", Desc{"b"}},
+		{"t1", Desc{"a\x00b"}},
+		{"t1", Desc{"a\x00"}},
+		{"t1", Desc{"a"}},
+		{"t1", Desc{"a"}, 1},
+		{"t1", Desc{""}},
+		{"t2", Desc{now.Add(time.Hour)}},
+		{"t2", Desc{now}},
+		{"t2", Desc{nil}},
+	}
+	var prev []byte
+	for i, tup := range ordered {
+		b, err := Encode(tup...)
+		if err != nil {
+			t.Fatalf("Encode err: %v", err)
+		}
+		if i > 0 && Compare(prev, b) >= 0 {
+			t.Errorf("expected %v < %v", ordered[i-1], tup)
+		}
+		prev = b
+	}
+
+	// A descending nil right after an ascending string or []byte must
+	// not read as the escape of a 0x00 inside it.
+	for _, v := range []interface{}{"a", []byte("a")} {
+		tup := []interface{}{v, Desc{nil}}
+		b, _ := Encode(tup...)
+		if got, err := Decode(b); err != nil || !reflect.DeepEqual(got, tup) {
+			t.Errorf("expected: %#v, got: %#v, err: %v", tup, got, err)
+		}
+		var nul interface{} = "a\x00"
+		if _, ok := v.([]byte); ok {
+			nul = []byte("a\x00")
+		}
+		ordered := [][]interface{}{{v}, {v, Desc{1}}, {v, Desc{nil}}, {nul}, {nul, Desc{nil}}}
+		var prev []byte
+		for i, tup := range ordered {
+			b, _ := Encode(tup...)
+			if i > 0 && Compare(prev, b) >= 0 {
+				t.Errorf("expected %#v < %#v", ordered[i-1], tup)
+			}
+			prev = b
+		}
+	}
+
+	if _, err := Encode(Desc{Desc{1}}); err == nil {
+		t.Errorf("expected an error for a nested Desc")
+	}
+	b, _ = Encode(Desc{"abc"})
+	if _, err := Decode(b[:len(b)-1]); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for a truncated Desc string, got: %v", err)
+	}
+}